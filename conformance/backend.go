@@ -0,0 +1,95 @@
+package conformance
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+)
+
+// Output 运行一个 Lox 程序的结果
+type Output struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Backend 执行 Lox 程序的方式, 所有后端使用同一套测试用例
+type Backend interface {
+	Name() string
+	Run(path string) (*Output, error)
+}
+
+// Interpreter 在当前进程中使用 interpreter 包执行
+type Interpreter struct{}
+
+func (Interpreter) Name() string {
+	return "interpreter"
+}
+
+func (Interpreter) Run(path string) (*Output, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	reporter := lox.NewReporter(&stderr)
+
+	s := scanner.NewScanner(string(source))
+	s.SetErrFunc(reporter.ScanError)
+	p := parser.NewParser(s.ScanTokens(), reporter.TokenError)
+	p.SetWarnFunc(reporter.Warning)
+	statements := p.Parse()
+	if reporter.HadError {
+		return &Output{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: lox.ExitSyntaxError}, nil
+	}
+
+	itp := interpreter.NewInterpreterWithConfig(interpreter.FullAccess())
+	itp.SetOutput(&stdout)
+	itp.SetReporter(reporter)
+	err = itp.Run(statements)
+	exit, exited := err.(*interpreter.Exit)
+	if err != nil && !exited {
+		reporter.RuntimeError(err)
+	}
+
+	out := &Output{Stdout: stdout.String(), Stderr: stderr.String()}
+	if reporter.HadRuntimeError {
+		out.ExitCode = lox.ExitRuntimeError
+	}
+	if exited {
+		out.ExitCode = exit.Code
+	}
+	return out, nil
+}
+
+// Command 执行外部命令, 程序路径作为最后一个参数, 例如参考实现 jlox
+type Command struct {
+	Path string
+	Args []string
+}
+
+func (c *Command) Name() string {
+	return c.Path
+}
+
+func (c *Command) Run(path string) (*Output, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(c.Path, append(append([]string{}, c.Args...), path)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	out := &Output{Stdout: stdout.String(), Stderr: stderr.String()}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		out.ExitCode = exitErr.ExitCode()
+		return out, nil
+	}
+	return out, err
+}
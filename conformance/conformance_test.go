@@ -0,0 +1,67 @@
+package conformance_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/conformance"
+)
+
+// TestInterpreter 用进程内的解释器运行 testdata/conformance 中的所有程序
+func TestInterpreter(t *testing.T) {
+	root := filepath.Join("..", "testdata", "conformance")
+	files, err := conformance.Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no test programs in %s", root)
+	}
+
+	for _, file := range files {
+		file := file
+		name, _ := filepath.Rel(root, file)
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			result := conformance.RunFile(conformance.Interpreter{}, file)
+			if result.Skipped {
+				t.Skip("nontest")
+			}
+			if len(result.Failures) > 0 {
+				t.Error(strings.Join(result.Failures, "\n"))
+			}
+		})
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	source := `print 1; // expect: 1
+var a = ; // Error at ';': Expect expression.
+match (1) { case _ => {} case 1 => {} } // Warning at 'case': Case can never match.
+`
+	e, err := conformance.ParseExpectations(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ExitCode != 65 || len(e.Output) != 1 || len(e.CompileErrors) != 1 || len(e.Warnings) != 1 {
+		t.Fatalf("unexpected expectations %+v", e)
+	}
+
+	out := &conformance.Output{
+		Stdout:   "1\n",
+		Stderr:   "[line 3] Warning at 'case': Case can never match.\n[line 2] Error at ';': Expect expression.\n",
+		ExitCode: 65,
+	}
+	if failures := e.Check(out); len(failures) > 0 {
+		t.Errorf("unexpected failures %v", failures)
+	}
+
+	out = &conformance.Output{Stdout: "2\n", Stderr: "[line 9] Error: other\n", ExitCode: 70}
+	if failures := e.Check(out); len(failures) != 5 {
+		t.Errorf("got %d failures, want 5: %q", len(failures), failures)
+	}
+
+	if _, err := conformance.ParseExpectations("// expect runtime error: a\n// expect runtime error: b\n"); err == nil {
+		t.Error("got no error for two runtime errors")
+	}
+}
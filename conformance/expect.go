@@ -0,0 +1,210 @@
+package conformance
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 注释格式与 craftinginterpreters 的测试用例一致
+var (
+	expectOutputPattern       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectErrorPattern        = regexp.MustCompile(`// (Error.*)`)
+	expectWarningPattern      = regexp.MustCompile(`// (Warning.*)`)
+	expectErrorLinePattern    = regexp.MustCompile(`// \[((java|c) )?line (\d+(?::\d+)?)\] (Error.*)`)
+	expectRuntimeErrorPattern = regexp.MustCompile(`// expect runtime error: (.+)`)
+	nonTestPattern            = regexp.MustCompile(`// nontest`)
+)
+
+// expectedOutput 一行期望的标准输出
+type expectedOutput struct {
+	Line int
+	Text string
+}
+
+// Expectations 从测试源码的注释中解析出的期望结果
+type Expectations struct {
+	Output           []expectedOutput
+	CompileErrors    []string // "[line N] Error ...", 扫描错误是 "[line N:C] Error ..."
+	Warnings         []string // "[line N] Warning ...", 不影响退出码
+	RuntimeError     string
+	RuntimeErrorLine int
+	ExitCode         int
+	Skip             bool
+}
+
+// ParseExpectations 解析 // expect: 等注释
+//
+// // [c line N] 形式的注释只适用于 clox, 会被忽略
+func ParseExpectations(source string) (*Expectations, error) {
+	e := &Expectations{}
+
+	sc := bufio.NewScanner(strings.NewReader(source))
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+
+		if nonTestPattern.MatchString(text) {
+			e.Skip = true
+			return e, nil
+		}
+
+		if m := expectOutputPattern.FindStringSubmatch(text); m != nil {
+			e.Output = append(e.Output, expectedOutput{Line: line, Text: m[1]})
+			continue
+		}
+
+		if m := expectErrorLinePattern.FindStringSubmatch(text); m != nil {
+			if m[2] != "c" {
+				e.CompileErrors = append(e.CompileErrors, fmt.Sprintf("[line %s] %s", m[3], m[4]))
+				e.ExitCode = 65
+			}
+			continue
+		}
+
+		if m := expectErrorPattern.FindStringSubmatch(text); m != nil {
+			e.CompileErrors = append(e.CompileErrors, fmt.Sprintf("[line %d] %s", line, m[1]))
+			e.ExitCode = 65
+			continue
+		}
+
+		if m := expectWarningPattern.FindStringSubmatch(text); m != nil {
+			e.Warnings = append(e.Warnings, fmt.Sprintf("[line %d] %s", line, m[1]))
+			continue
+		}
+
+		if m := expectRuntimeErrorPattern.FindStringSubmatch(text); m != nil {
+			if e.RuntimeError != "" {
+				return nil, fmt.Errorf("line %d: only one runtime error can be expected", line)
+			}
+			e.RuntimeError = m[1]
+			e.RuntimeErrorLine = line
+			e.ExitCode = 70
+		}
+	}
+
+	if e.RuntimeError != "" && len(e.CompileErrors) > 0 {
+		return nil, fmt.Errorf("cannot expect both compile and runtime errors")
+	}
+	return e, sc.Err()
+}
+
+// Check 比较实际输出与期望, 返回所有不一致的地方
+func (e *Expectations) Check(out *Output) []string {
+	var failures []string
+	fail := func(format string, args ...interface{}) {
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+
+	stderr := e.checkWarnings(splitLines(out.Stderr), fail)
+	if e.RuntimeError != "" {
+		failures = append(failures, e.checkRuntimeError(stderr)...)
+	} else {
+		failures = append(failures, e.checkCompileErrors(stderr)...)
+	}
+
+	if out.ExitCode != e.ExitCode {
+		fail("Expected exit code %d but got %d.", e.ExitCode, out.ExitCode)
+	}
+
+	stdout := splitLines(out.Stdout)
+	for i, line := range stdout {
+		if i >= len(e.Output) {
+			fail("Got output %q when none was expected.", line)
+			continue
+		}
+		if expected := e.Output[i]; expected.Text != line {
+			fail("Expected output %q on line %d but got %q.", expected.Text, expected.Line, line)
+		}
+	}
+	for _, expected := range e.Output[min(len(stdout), len(e.Output)):] {
+		fail("Missing expected output %q on line %d.", expected.Text, expected.Line)
+	}
+
+	return failures
+}
+
+// checkWarnings 检查并去掉期望的警告, 警告在解析时输出, 位于所有错误之前
+func (e *Expectations) checkWarnings(stderr []string, fail func(format string, args ...interface{})) []string {
+	expected := make(map[string]bool, len(e.Warnings))
+	for _, msg := range e.Warnings {
+		expected[msg] = true
+	}
+
+	found := make(map[string]bool)
+	var rest []string
+	for _, line := range stderr {
+		if expected[line] {
+			found[line] = true
+			continue
+		}
+		rest = append(rest, line)
+	}
+
+	for _, msg := range e.Warnings {
+		if !found[msg] {
+			fail("Missing expected warning: %s", msg)
+		}
+	}
+	return rest
+}
+
+func (e *Expectations) checkRuntimeError(stderr []string) []string {
+	if len(stderr) < 2 {
+		return []string{fmt.Sprintf("Expected runtime error %q and got none.", e.RuntimeError)}
+	}
+
+	var failures []string
+	if stderr[0] != e.RuntimeError {
+		failures = append(failures, fmt.Sprintf("Expected runtime error %q and got %q.", e.RuntimeError, stderr[0]))
+	}
+
+	m := regexp.MustCompile(`\[line (\d+)\]`).FindStringSubmatch(stderr[1])
+	if m == nil {
+		failures = append(failures, fmt.Sprintf("Expected stack trace and got %q.", stderr[1]))
+	} else if line, _ := strconv.Atoi(m[1]); line != e.RuntimeErrorLine {
+		failures = append(failures, fmt.Sprintf("Expected runtime error on line %d but was on line %d.", e.RuntimeErrorLine, line))
+	}
+	return failures
+}
+
+func (e *Expectations) checkCompileErrors(stderr []string) []string {
+	var failures []string
+
+	expected := make(map[string]bool, len(e.CompileErrors))
+	for _, msg := range e.CompileErrors {
+		expected[msg] = true
+	}
+
+	found := make(map[string]bool)
+	for _, line := range stderr {
+		if !expected[line] {
+			failures = append(failures, fmt.Sprintf("Unexpected output on stderr: %q", line))
+			continue
+		}
+		found[line] = true
+	}
+
+	for _, msg := range e.CompileErrors {
+		if !found[msg] {
+			failures = append(failures, fmt.Sprintf("Missing expected error: %s", msg))
+		}
+	}
+	return failures
+}
+
+func splitLines(s string) []string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
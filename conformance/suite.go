@@ -0,0 +1,113 @@
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Result 一个测试程序的结果
+type Result struct {
+	Path     string
+	Skipped  bool
+	Failures []string
+}
+
+func (r *Result) Passed() bool {
+	return !r.Skipped && len(r.Failures) == 0
+}
+
+// Discover 递归查找 root 下的 .lox 文件, root 也可以是单个文件
+func Discover(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".lox" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// RunFile 用 backend 执行 path 并与注释中的期望比较
+func RunFile(backend Backend, path string) *Result {
+	result := &Result{Path: path}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		result.Failures = []string{err.Error()}
+		return result
+	}
+
+	expect, err := ParseExpectations(string(source))
+	if err != nil {
+		result.Failures = []string{err.Error()}
+		return result
+	}
+	if expect.Skip {
+		result.Skipped = true
+		return result
+	}
+
+	out, err := backend.Run(path)
+	if err != nil {
+		result.Failures = []string{err.Error()}
+		return result
+	}
+
+	result.Failures = expect.Check(out)
+	return result
+}
+
+// Run 执行 root 下的所有测试程序
+func Run(backend Backend, root string) ([]*Result, error) {
+	files, err := Discover(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, path := range files {
+		results = append(results, RunFile(backend, path))
+	}
+	return results, nil
+}
+
+// WriteReport 输出失败的测试和汇总, 返回失败的个数
+func WriteReport(w io.Writer, backend Backend, results []*Result) int {
+	passed, failed, skipped := 0, 0, 0
+
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			skipped++
+		case r.Passed():
+			passed++
+		default:
+			failed++
+			fmt.Fprintf(w, "FAIL %s\n", r.Path)
+			for _, f := range r.Failures {
+				fmt.Fprintf(w, "     %s\n", strings.TrimSpace(f))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%s: %d passed, %d failed, %d skipped\n", backend.Name(), passed, failed, skipped)
+	return failed
+}
@@ -0,0 +1,114 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lox coverage</title>
+<style>
+body { font-family: sans-serif; }
+table.src { border-collapse: collapse; font-family: monospace; }
+table.src td { padding: 0 8px; white-space: pre; }
+td.num, td.hits { text-align: right; color: #888; }
+tr.covered td.code { background: #dfd; }
+tr.uncovered td.code { background: #fdd; }
+tr.partial td.code { background: #ffc; }
+</style>
+</head>
+<body>
+<h1>Lox coverage</h1>
+<ul>
+{{range .}}<li><a href="#{{.ID}}">{{.Path}}</a> lines {{.LinesHit}}/{{.LinesFound}}, branches {{.BranchesHit}}/{{.BranchesFound}}</li>
+{{end}}</ul>
+{{range .}}
+<h2 id="{{.ID}}">{{.Path}}</h2>
+{{if .Err}}<p>{{.Err}}</p>{{else}}
+<table class="src">
+{{range .Lines}}<tr class="{{.Class}}"><td class="num">{{.Number}}</td><td class="hits">{{.Hits}}</td><td class="hits">{{.Branches}}</td><td class="code">{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+type htmlLine struct {
+	Number   int
+	Hits     string
+	Branches string
+	Class    string
+	Text     string
+}
+
+type htmlFile struct {
+	ID            string
+	Path          string
+	Err           error
+	LinesFound    int
+	LinesHit      int
+	BranchesFound int
+	BranchesHit   int
+	Lines         []htmlLine
+}
+
+// WriteHTML 带执行次数标注的源码, 源文件从 File.Path 读取
+func (r *Report) WriteHTML(w io.Writer) error {
+	var files []htmlFile
+	for i, path := range r.paths() {
+		files = append(files, r.Files[path].html(fmt.Sprintf("file%d", i)))
+	}
+	return htmlTemplate.Execute(w, files)
+}
+
+func (f *File) html(id string) htmlFile {
+	hf := htmlFile{ID: id, Path: f.Path, LinesFound: len(f.Lines), BranchesFound: len(f.Branches)}
+	for _, n := range f.Lines {
+		if n > 0 {
+			hf.LinesHit++
+		}
+	}
+
+	taken := make(map[int]int)
+	total := make(map[int]int)
+	for id, n := range f.Branches {
+		total[id.Line]++
+		if n > 0 {
+			taken[id.Line]++
+			hf.BranchesHit++
+		}
+	}
+
+	source, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		hf.Err = err
+		return hf
+	}
+
+	for i, text := range strings.Split(string(source), "\n") {
+		number := i + 1
+		line := htmlLine{Number: number, Text: text}
+
+		if n, ok := f.Lines[number]; ok {
+			line.Hits = fmt.Sprintf("%dx", n)
+			line.Class = "uncovered"
+			if n > 0 {
+				line.Class = "covered"
+			}
+		}
+		if total[number] > 0 {
+			line.Branches = fmt.Sprintf("%d/%d", taken[number], total[number])
+			if taken[number] < total[number] && line.Class == "covered" {
+				line.Class = "partial"
+			}
+		}
+		hf.Lines = append(hf.Lines, line)
+	}
+	return hf
+}
@@ -0,0 +1,288 @@
+package coverage
+
+import (
+	"sync"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+// Recorder 记录一个脚本执行过的语句和分支, 实现 interpreter.Hook 和 interpreter.BranchHook
+type Recorder struct {
+	mu       sync.Mutex // spawn 出的任务会同时调用钩子
+	file     *File
+	branches map[interface{}]BranchID // 分支结构 -> 该结构的第 0 个出口
+}
+
+// NewRecorder 预先登记 statements 中所有的语句和分支, 未执行的记为 0
+func NewRecorder(path string, statements []expr.Stmt) *Recorder {
+	r := &Recorder{file: newFile(path), branches: make(map[interface{}]BranchID)}
+
+	w := &walker{r: r, blocks: make(map[int]int)}
+	w.stmts(statements)
+	return r
+}
+
+// Report 到目前为止的覆盖率
+func (r *Recorder) Report() *Report {
+	report := NewReport()
+	report.Files[r.file.Path] = r.file
+	return report
+}
+
+// BeforeExecute 实现 interpreter.Hook
+func (r *Recorder) BeforeExecute(itp *interpreter.Interpreter, stmt expr.Stmt) {
+	if _, ok := stmt.(*expr.Block); ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file.Lines[stmt.Line()]++
+}
+
+// Branch 实现 interpreter.BranchHook, 第 0 个出口为 taken
+func (r *Recorder) Branch(itp *interpreter.Interpreter, node interface{}, taken bool) {
+	id, ok := r.branches[node]
+	if !ok {
+		return
+	}
+	if !taken {
+		id.Branch = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.file.Branches[id]++
+}
+
+// walker 遍历语法树, 登记语句行和分支结构
+type walker struct {
+	r      *Recorder
+	blocks map[int]int // 每行已经登记的分支结构个数
+}
+
+func (w *walker) branch(node interface{}, line int) {
+	id := BranchID{Line: line, Block: w.blocks[line]}
+	w.blocks[line]++
+
+	w.r.branches[node] = id
+	w.r.file.Branches[id] = 0
+	w.r.file.Branches[BranchID{Line: line, Block: id.Block, Branch: 1}] = 0
+}
+
+func (w *walker) stmts(statements []expr.Stmt) {
+	for _, stmt := range statements {
+		if stmt != nil {
+			stmt.Accept(w)
+		}
+	}
+}
+
+func (w *walker) expr(e expr.Expr) {
+	if e != nil {
+		e.Accept(w)
+	}
+}
+
+func (w *walker) stmt(stmt expr.Stmt) {
+	if _, ok := stmt.(*expr.Block); !ok {
+		w.r.file.Lines[stmt.Line()] = 0
+	}
+}
+
+func (w *walker) VisitorBinaryExpr(e *expr.Binary) interface{} {
+	w.expr(e.Left)
+	w.expr(e.Right)
+	return nil
+}
+
+func (w *walker) VisitorGroupingExpr(e *expr.Grouping) interface{} {
+	w.expr(e.Expression)
+	return nil
+}
+
+func (w *walker) VisitorLiteralExpr(e *expr.Literal) interface{} {
+	return nil
+}
+
+func (w *walker) VisitorInterpolationExpr(e *expr.Interpolation) interface{} {
+	for _, part := range e.Parts {
+		w.expr(part)
+	}
+	return nil
+}
+
+func (w *walker) VisitorLogicalExpr(e *expr.Logical) interface{} {
+	w.expr(e.Left)
+	w.branch(e, e.Operator.Line)
+	w.expr(e.Right)
+	return nil
+}
+
+func (w *walker) VisitorGetExpr(e *expr.Get) interface{} {
+	w.expr(e.Object)
+	return nil
+}
+
+func (w *walker) VisitorSetExpr(e *expr.Set) interface{} {
+	w.expr(e.Object)
+	w.expr(e.Value)
+	return nil
+}
+
+func (w *walker) VisitorMapExpr(e *expr.Map) interface{} {
+	for i := range e.Keys {
+		w.expr(e.Keys[i])
+		w.expr(e.Values[i])
+	}
+	return nil
+}
+
+func (w *walker) VisitorListExpr(e *expr.List) interface{} {
+	for _, element := range e.Elements {
+		w.expr(element)
+	}
+	return nil
+}
+
+func (w *walker) VisitorConditionalExpr(e *expr.Conditional) interface{} {
+	w.expr(e.Condition)
+	w.branch(e, e.Question.Line)
+	w.expr(e.Then)
+	w.expr(e.Else)
+	return nil
+}
+
+func (w *walker) VisitorUnaryExpr(e *expr.Unary) interface{} {
+	w.expr(e.Right)
+	return nil
+}
+
+func (w *walker) VisitorVariableExpr(e *expr.Variable) interface{} {
+	return nil
+}
+
+func (w *walker) VisitorUpdateExpr(e *expr.Update) interface{} {
+	if e.Object != nil {
+		w.expr(e.Object)
+	}
+	return nil
+}
+
+func (w *walker) VisitorAssignExpr(e *expr.Assign) interface{} {
+	w.expr(e.Value)
+	return nil
+}
+
+func (w *walker) VisitorCallExpr(e *expr.Call) interface{} {
+	w.expr(e.Callee)
+	for _, arg := range e.Arguments {
+		w.expr(arg)
+	}
+	return nil
+}
+
+func (w *walker) VisitorExpressionStmtExpr(stmt *expr.Expression) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Expression)
+	return nil
+}
+
+func (w *walker) VisitorPrintStmtExpr(stmt *expr.Print) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Print)
+	return nil
+}
+
+func (w *walker) VisitorReturnStmtExpr(stmt *expr.Return) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Value)
+	return nil
+}
+
+func (w *walker) VisitorYieldStmtExpr(stmt *expr.Yield) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Value)
+	return nil
+}
+
+func (w *walker) VisitorVarStmtExpr(stmt *expr.Var) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Initializer)
+	return nil
+}
+
+func (w *walker) VisitorWhileStmtExpr(stmt *expr.While) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Condition)
+	w.branch(stmt, stmt.Line())
+	w.stmts([]expr.Stmt{stmt.Body})
+	return nil
+}
+
+func (w *walker) VisitorForInStmtExpr(stmt *expr.ForIn) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Iterable)
+	w.branch(stmt, stmt.Line())
+	w.stmts([]expr.Stmt{stmt.Body})
+	return nil
+}
+
+func (w *walker) VisitorBlockStmtExpr(stmt *expr.Block) interface{} {
+	w.stmts(stmt.Statements)
+	return nil
+}
+
+func (w *walker) VisitorIFStmtExpr(stmt *expr.IF) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Condition)
+	w.branch(stmt, stmt.Line())
+	w.stmts([]expr.Stmt{stmt.ThenBranch, stmt.ElseBranch})
+	return nil
+}
+
+func (w *walker) VisitorFunStmtExpr(stmt *expr.Function) interface{} {
+	w.stmt(stmt)
+	w.stmts(stmt.Body)
+	return nil
+}
+
+func (w *walker) VisitorAssertStmtExpr(stmt *expr.Assert) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Condition)
+	w.expr(stmt.Message)
+	return nil
+}
+
+func (w *walker) VisitorTestStmtExpr(stmt *expr.Test) interface{} {
+	w.stmts(stmt.Body)
+	return nil
+}
+
+func (w *walker) VisitorMatchStmtExpr(stmt *expr.Match) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Value)
+	for _, c := range stmt.Cases {
+		w.branch(c, c.Keyword.Line)
+		w.expr(c.Guard)
+		w.stmts([]expr.Stmt{c.Body})
+	}
+	return nil
+}
+
+func (w *walker) VisitorSpawnStmtExpr(stmt *expr.Spawn) interface{} {
+	w.stmt(stmt)
+	w.expr(stmt.Call)
+	return nil
+}
+
+func (w *walker) VisitorSelectStmtExpr(stmt *expr.Select) interface{} {
+	w.stmt(stmt)
+	for _, c := range stmt.Cases {
+		w.expr(c.Channel)
+		w.expr(c.Value)
+		w.stmts([]expr.Stmt{c.Body})
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package coverage_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/coverage"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+const source = "testdata/branches.lox"
+
+// record 运行 source 一次, 返回记录的覆盖率
+func record(t *testing.T) *coverage.Report {
+	t.Helper()
+
+	text, err := ioutil.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewParser(scanner.NewScanner(string(text)).ScanTokens(), func(tk *token.Token, msg string) {
+		t.Fatalf("line %d: %s", tk.Line, msg)
+	})
+	statements := p.Parse()
+
+	recorder := coverage.NewRecorder(source, statements)
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(&bytes.Buffer{})
+	itp.SetHook(recorder)
+	if err := itp.Run(statements); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Report()
+}
+
+func TestRecorder(t *testing.T) {
+	f := record(t).Files[source]
+
+	// 第 6 和 12 行只有 }, 不是语句
+	lines := map[int]int{1: 1, 2: 1, 3: 2, 4: 1, 5: 0, 7: 1, 8: 1, 9: 1, 10: 0, 11: 1}
+	if len(f.Lines) != len(lines) {
+		t.Errorf("got %d lines, want %d: %v", len(f.Lines), len(lines), f.Lines)
+	}
+	for line, n := range lines {
+		if got, ok := f.Lines[line]; !ok || got != n {
+			t.Errorf("line %d: got %d (registered %v), want %d", line, got, ok, n)
+		}
+	}
+
+	branches := []struct {
+		name           string
+		line           int
+		taken, skipped int
+	}{
+		{"while", 2, 2, 1},
+		{"if", 4, 0, 1},
+		{"and", 7, 0, 1},
+		{"?:", 8, 0, 1},
+		{"case 1", 10, 0, 1},
+		{"case _", 11, 1, 0},
+	}
+	if len(f.Branches) != 2*len(branches) {
+		t.Errorf("got %d branches, want %d: %v", len(f.Branches), 2*len(branches), f.Branches)
+	}
+	for _, b := range branches {
+		taken := f.Branches[coverage.BranchID{Line: b.line}]
+		skipped := f.Branches[coverage.BranchID{Line: b.line, Branch: 1}]
+		if taken != b.taken || skipped != b.skipped {
+			t.Errorf("%s on line %d: got %d/%d, want %d/%d", b.name, b.line, taken, skipped, b.taken, b.skipped)
+		}
+	}
+}
+
+// TestBranchBlocks 同一行中的多个分支结构按出现顺序编号
+func TestBranchBlocks(t *testing.T) {
+	p := parser.NewParser(scanner.NewScanner("var a = true or false ? 1 : 2;").ScanTokens(), nil)
+	f := coverage.NewRecorder("one_line.lox", p.Parse()).Report().Files["one_line.lox"]
+
+	ids := []coverage.BranchID{
+		{Line: 1, Block: 0, Branch: 0},
+		{Line: 1, Block: 0, Branch: 1},
+		{Line: 1, Block: 1, Branch: 0},
+		{Line: 1, Block: 1, Branch: 1},
+	}
+	for _, id := range ids {
+		if n, ok := f.Branches[id]; !ok || n != 0 {
+			t.Errorf("branch %+v: got %d (registered %v), want 0", id, n, ok)
+		}
+	}
+}
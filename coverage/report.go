@@ -0,0 +1,179 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BranchID LCOV 中的一个分支: 行号, 该行中第几个分支结构, 第几个出口
+type BranchID struct {
+	Line   int
+	Block  int
+	Branch int
+}
+
+// File 一个源文件的覆盖率, 值为执行次数
+type File struct {
+	Path     string
+	Lines    map[int]int
+	Branches map[BranchID]int
+}
+
+func newFile(path string) *File {
+	return &File{Path: path, Lines: make(map[int]int), Branches: make(map[BranchID]int)}
+}
+
+// Report 多个源文件的覆盖率, 可以合并多次运行的结果
+type Report struct {
+	Files map[string]*File
+}
+
+func NewReport() *Report {
+	return &Report{Files: make(map[string]*File)}
+}
+
+func (r *Report) file(path string) *File {
+	f, ok := r.Files[path]
+	if !ok {
+		f = newFile(path)
+		r.Files[path] = f
+	}
+	return f
+}
+
+// Merge 把 other 的执行次数累加到 r
+func (r *Report) Merge(other *Report) {
+	for path, of := range other.Files {
+		f := r.file(path)
+		for line, n := range of.Lines {
+			f.Lines[line] += n
+		}
+		for id, n := range of.Branches {
+			f.Branches[id] += n
+		}
+	}
+}
+
+func (r *Report) paths() []string {
+	var paths []string
+	for path := range r.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WriteLCOV 以 LCOV tracefile 格式写出
+// http://ltp.sourceforge.net/coverage/lcov/geninfo.1.php
+func (r *Report) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, path := range r.paths() {
+		f := r.Files[path]
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", path)
+
+		branches := f.sortedBranches()
+		hit := 0
+		for _, id := range branches {
+			n := f.Branches[id]
+			if n > 0 {
+				hit++
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%d\n", id.Line, id.Block, id.Branch, n)
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", len(branches), hit)
+
+		lines := f.sortedLines()
+		hit = 0
+		for _, line := range lines {
+			n := f.Lines[line]
+			if n > 0 {
+				hit++
+			}
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, n)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+	}
+
+	return bw.Flush()
+}
+
+// ReadLCOV 读取 WriteLCOV 写出的文件, 只识别 SF, DA 和 BRDA
+func ReadLCOV(rd io.Reader) (*Report, error) {
+	r := NewReport()
+	var f *File
+
+	sc := bufio.NewScanner(rd)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		key, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			key, value = line[:i], line[i+1:]
+		}
+
+		switch key {
+		case "SF":
+			f = r.file(value)
+		case "end_of_record":
+			f = nil
+		case "DA", "BRDA":
+			if f == nil {
+				return nil, fmt.Errorf("lcov line %d: %s outside of a record", n, key)
+			}
+
+			fields := strings.Split(value, ",")
+			nums := make([]int, len(fields))
+			for i, field := range fields {
+				if field == "-" {
+					continue
+				}
+				v, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("lcov line %d: %v", n, err)
+				}
+				nums[i] = v
+			}
+
+			if key == "DA" && len(nums) >= 2 {
+				f.Lines[nums[0]] += nums[1]
+			} else if key == "BRDA" && len(nums) == 4 {
+				f.Branches[BranchID{Line: nums[0], Block: nums[1], Branch: nums[2]}] += nums[3]
+			} else {
+				return nil, fmt.Errorf("lcov line %d: malformed %s", n, key)
+			}
+		}
+	}
+
+	return r, sc.Err()
+}
+
+func (f *File) sortedLines() []int {
+	var lines []int
+	for line := range f.Lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func (f *File) sortedBranches() []BranchID {
+	var ids []BranchID
+	for id := range f.Branches {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Block != b.Block {
+			return a.Block < b.Block
+		}
+		return a.Branch < b.Branch
+	})
+	return ids
+}
@@ -0,0 +1,103 @@
+package coverage_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/coverage"
+)
+
+func TestWriteLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := record(t).WriteLCOV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/branches.lcov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(golden) {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), golden)
+	}
+}
+
+func TestReadLCOV(t *testing.T) {
+	report := record(t)
+
+	var buf bytes.Buffer
+	if err := report.WriteLCOV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := coverage.ReadLCOV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, report) {
+		t.Errorf("round trip changed the report:\ngot  %+v\nwant %+v", read.Files[source], report.Files[source])
+	}
+
+	for _, bad := range []string{
+		"DA:1,1\n",
+		"SF:a.lox\nDA:1\nend_of_record\n",
+		"SF:a.lox\nBRDA:1,0,x,1\nend_of_record\n",
+	} {
+		if _, err := coverage.ReadLCOV(strings.NewReader(bad)); err == nil {
+			t.Errorf("ReadLCOV(%q) succeeded", bad)
+		}
+	}
+
+	// lcov 用 - 表示从未执行过的分支
+	r, err := coverage.ReadLCOV(strings.NewReader("SF:a.lox\nBRDA:3,0,1,-\nend_of_record\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := r.Files["a.lox"].Branches[coverage.BranchID{Line: 3, Branch: 1}]; !ok || n != 0 {
+		t.Errorf("got %d (registered %v) for an unexecuted branch, want 0", n, ok)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	report := record(t)
+	report.Merge(record(t))
+
+	other := coverage.NewReport()
+	other.Files["other.lox"] = &coverage.File{
+		Path:     "other.lox",
+		Lines:    map[int]int{1: 1},
+		Branches: map[coverage.BranchID]int{},
+	}
+	report.Merge(other)
+
+	f := report.Files[source]
+	if f.Lines[3] != 4 || f.Lines[5] != 0 {
+		t.Errorf("got line 3 = %d and line 5 = %d after two runs, want 4 and 0", f.Lines[3], f.Lines[5])
+	}
+	if n := f.Branches[coverage.BranchID{Line: 2}]; n != 4 {
+		t.Errorf("got while branch %d after two runs, want 4", n)
+	}
+	if len(report.Files) != 2 || report.Files["other.lox"].Lines[1] != 1 {
+		t.Errorf("got files %v, want %s and other.lox", report.Files, source)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := record(t).WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"lines 8/10, branches 7/12",
+		`<tr class="covered"><td class="num">3</td><td class="hits">2x</td>`,
+		`<tr class="partial"><td class="num">4</td><td class="hits">1x</td><td class="hits">1/2</td>`,
+		`<tr class="uncovered"><td class="num">5</td><td class="hits">0x</td>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("HTML report does not contain %s", want)
+		}
+	}
+}
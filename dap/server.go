@@ -0,0 +1,427 @@
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/zhiruchen/lox-go/debugger"
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// threadID Lox 程序只有一个线程
+const threadID = 1
+
+// Server 基于 stdio 的 Lox debug adapter
+type Server struct {
+	conn     *conn
+	defaults *interpreter.Config
+
+	program     string
+	stopOnEntry bool
+	statements  []expr.Stmt
+	lines       map[int]bool
+	itp         *interpreter.Interpreter
+	dbg         *debugger.Debugger
+
+	// variablesReference -> 作用域, 程序继续执行后失效
+	scopes map[int]*interpreter.Env
+}
+
+// NewServer 从 in 读取请求, 向 out 写入响应和事件; 与命令行的默认值一样授予所有能力
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return NewServerWithConfig(in, out, interpreter.FullAccess())
+}
+
+// NewServerWithConfig launch 参数中没有 allow 时被调试的程序使用 defaults 授予的能力
+func NewServerWithConfig(in io.Reader, out io.Writer, defaults *interpreter.Config) *Server {
+	return &Server{conn: newConn(in, out), defaults: defaults}
+}
+
+// Serve 处理请求直到 disconnect 或者输入结束
+func (s *Server) Serve() error {
+	for {
+		req, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		body, err := s.dispatch(req)
+		if err := s.conn.respond(req, body, err); err != nil {
+			return err
+		}
+
+		switch req.Command {
+		case "initialize":
+			if err := s.conn.event("initialized", nil); err != nil {
+				return err
+			}
+		case "disconnect", "terminate":
+			return nil
+		}
+	}
+}
+
+func (s *Server) dispatch(req *request) (interface{}, error) {
+	if needsProgram[req.Command] && s.dbg == nil {
+		return nil, errNotLaunched
+	}
+
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "setExceptionBreakpoints":
+		return map[string]interface{}{}, nil
+	case "configurationDone":
+		return nil, s.start()
+	case "threads":
+		return map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		var args StackTraceArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.stackTrace(args)
+	case "scopes":
+		var args ScopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopesOf(args.FrameID)
+	case "variables":
+		var args VariablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+	case "evaluate":
+		var args EvaluateArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, s.resume(s.dbg.Continue)
+	case "next":
+		return nil, s.resume(s.dbg.StepOver)
+	case "stepIn":
+		return nil, s.resume(s.dbg.StepIn)
+	case "stepOut":
+		return nil, s.resume(s.dbg.StepOut)
+	case "pause":
+		s.dbg.Pause()
+		return nil, nil
+	case "disconnect", "terminate":
+		if s.dbg != nil {
+			s.dbg.Stop()
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported request: %s", req.Command)
+}
+
+var errNotLaunched = errors.New("no program launched")
+
+// needsProgram 这些请求只能在 launch 之后处理
+var needsProgram = map[string]bool{
+	"configurationDone": true,
+	"stackTrace":        true,
+	"scopes":            true,
+	"variables":         true,
+	"evaluate":          true,
+	"continue":          true,
+	"next":              true,
+	"stepIn":            true,
+	"stepOut":           true,
+	"pause":             true,
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	source, err := ioutil.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	cfg := s.defaults
+	if args.Allow != nil {
+		if cfg, err = interpreter.NewConfig(args.Allow, args.AllowPaths); err != nil {
+			return err
+		}
+	}
+	cfg = cfg.WithArgs(args.Args)
+
+	reporter := s.reporter()
+	sc := scanner.NewScanner(string(source))
+	sc.SetErrFunc(reporter.ScanError)
+	p := parser.NewParser(sc.ScanTokens(), reporter.TokenError)
+	p.SetWarnFunc(reporter.Warning)
+	statements := p.Parse()
+	if reporter.HadError {
+		return errors.New("program has syntax errors")
+	}
+
+	s.program = args.Program
+	s.stopOnEntry = args.StopOnEntry
+	s.statements = statements
+	s.lines = debugger.Lines(statements)
+	s.itp = interpreter.NewInterpreterWithConfig(cfg)
+	s.itp.SetOutput(writerFunc(func(p []byte) (int, error) {
+		return len(p), s.output("stdout", string(p))
+	}))
+	s.itp.SetReporter(reporter)
+	s.dbg = debugger.New(s.itp)
+	if args.NoDebug {
+		s.itp.SetHook(nil)
+	}
+	return nil
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) map[string]interface{} {
+	breakpoints := []Breakpoint{}
+	var lines []int
+	for _, bp := range args.Breakpoints {
+		verified := s.lines == nil || s.lines[bp.Line]
+		b := Breakpoint{Verified: verified, Line: bp.Line}
+		if verified {
+			lines = append(lines, bp.Line)
+		} else {
+			b.Message = "no statement on this line"
+		}
+		breakpoints = append(breakpoints, b)
+	}
+
+	if s.dbg != nil {
+		s.dbg.SetBreakpoints(lines)
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+func (s *Server) start() error {
+	s.dbg.Run(s.statements, s.stopOnEntry)
+	go s.forwardEvents(s.dbg)
+	return nil
+}
+
+// forwardEvents 把调试器的事件转发给 client
+func (s *Server) forwardEvents(dbg *debugger.Debugger) {
+	for ev := range dbg.Events() {
+		switch ev.Kind {
+		case debugger.EventStopped:
+			s.conn.event("stopped", map[string]interface{}{
+				"reason":            ev.Reason,
+				"threadId":          threadID,
+				"allThreadsStopped": true,
+			})
+		case debugger.EventTerminated:
+			exitCode := 0
+			if exit, ok := ev.Err.(*interpreter.Exit); ok {
+				exitCode = exit.Code
+			} else if ev.Err != nil {
+				exitCode = lox.ExitRuntimeError
+				s.itp.Reporter().RuntimeError(ev.Err)
+			}
+			s.conn.event("exited", map[string]interface{}{"exitCode": exitCode})
+			s.conn.event("terminated", nil)
+		}
+	}
+}
+
+// reporter 与 lox run 格式相同的 Reporter, 输出为 stderr 类别的 output 事件
+func (s *Server) reporter() *lox.Reporter {
+	return lox.NewReporter(writerFunc(func(p []byte) (int, error) {
+		return len(p), s.output("stderr", string(p))
+	}))
+}
+
+func (s *Server) output(category, text string) error {
+	return s.conn.event("output", map[string]interface{}{
+		"category": category,
+		"output":   text,
+	})
+}
+
+func (s *Server) resume(step func() error) error {
+	s.scopes = nil
+	return step()
+}
+
+// frames 调用栈, 最内层的帧在最前, 与 DAP 的 frameId 顺序一致
+func (s *Server) frames() ([]interpreter.Frame, error) {
+	var frames []interpreter.Frame
+	err := s.dbg.Inspect(func(itp *interpreter.Interpreter) {
+		frames = itp.Frames()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames, nil
+}
+
+func (s *Server) stackTrace(args StackTraceArguments) (interface{}, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+
+	source := Source{Name: filepath.Base(s.program), Path: s.program}
+	stackFrames := []StackFrame{}
+	for i, f := range frames {
+		if i < args.StartFrame {
+			continue
+		}
+		if args.Levels > 0 && len(stackFrames) >= args.Levels {
+			break
+		}
+		stackFrames = append(stackFrames, StackFrame{ID: i, Name: f.Name, Source: source, Line: f.Line, Column: 1})
+	}
+
+	return map[string]interface{}{
+		"stackFrames": stackFrames,
+		"totalFrames": len(frames),
+	}, nil
+}
+
+func (s *Server) frameEnv(frameID int) (*interpreter.Env, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+	if frameID < 0 || frameID >= len(frames) {
+		return nil, fmt.Errorf("invalid frame id %d", frameID)
+	}
+	return frames[frameID].Env, nil
+}
+
+// scopesOf 帧所在环境链上的每个作用域
+func (s *Server) scopesOf(frameID int) (interface{}, error) {
+	env, err := s.frameEnv(frameID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.scopes == nil {
+		s.scopes = make(map[int]*interpreter.Env)
+	}
+
+	scopes := []Scope{}
+	for depth := 0; env != nil; depth, env = depth+1, env.Enclosing {
+		ref := len(s.scopes) + 1
+		s.scopes[ref] = env
+
+		name := "Locals"
+		if env == s.itp.GetGlobalEnv() {
+			name = "Globals"
+		} else if depth > 0 {
+			name = fmt.Sprintf("Enclosing %d", depth)
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: ref, Expensive: name == "Globals"})
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(ref int) (interface{}, error) {
+	env, ok := s.scopes[ref]
+	if !ok {
+		return nil, fmt.Errorf("invalid variables reference %d", ref)
+	}
+
+	var values map[string]interface{}
+	variables := []Variable{}
+	err := s.dbg.Inspect(func(itp *interpreter.Interpreter) {
+		values = env.Values()
+
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			variables = append(variables, Variable{Name: name, Value: itp.Stringify(values[name])})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (interface{}, error) {
+	frameID := 0
+	if args.FrameID != nil {
+		frameID = *args.FrameID
+	}
+	env, err := s.frameEnv(frameID)
+	if err != nil {
+		return nil, err
+	}
+
+	var parseErr string
+	sc := scanner.NewScanner(args.Expression)
+	sc.SetErrFunc(func(line, column int, msg string) { parseErr = msg })
+	p := parser.NewParser(sc.ScanTokens(), func(tk *token.Token, msg string) {})
+	exp, err := p.ParseExpression()
+	if parseErr != "" {
+		return nil, errors.New(parseErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result string
+	var evalErr error
+	err = s.dbg.Inspect(func(itp *interpreter.Interpreter) {
+		var v interface{}
+		v, evalErr = itp.Evaluate(exp, env)
+		result = itp.Stringify(v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if evalErr != nil {
+		return nil, evalErr
+	}
+
+	return map[string]interface{}{"result": result, "variablesReference": 0}, nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,410 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// message server 发出的 response 或者 event
+type message struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client 在进程内驱动 Server, 事件按到达顺序排队
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	seq      int
+	messages chan *message
+	events   []*message
+}
+
+func start(t *testing.T) *client {
+	t.Helper()
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- NewServer(serverR, serverW).Serve() }()
+
+	c := &client{t: t, w: clientW, messages: make(chan *message, 64)}
+	go c.loop(textproto.NewReader(bufio.NewReader(clientR)))
+	t.Cleanup(func() {
+		clientW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+		clientR.Close()
+	})
+	return c
+}
+
+func (c *client) loop(r *textproto.Reader) {
+	defer close(c.messages)
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			return
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		c.messages <- &msg
+	}
+}
+
+func (c *client) next() *message {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// call 发送请求并返回响应, 期间收到的事件留给 event
+func (c *client) call(command string, arguments, body interface{}) *message {
+	c.t.Helper()
+
+	c.seq++
+	raw, _ := json.Marshal(map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   command,
+		"arguments": arguments,
+	})
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(raw), raw); err != nil {
+		c.t.Fatalf("%s: %v", command, err)
+	}
+
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != c.seq {
+			c.t.Fatalf("got response to request %d, want %d", msg.RequestSeq, c.seq)
+		}
+		if msg.Success && body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("%s: %v", command, err)
+			}
+		}
+		return msg
+	}
+}
+
+// mustCall 与 call 相同, 但请求失败时结束测试
+func (c *client) mustCall(command string, arguments, body interface{}) {
+	c.t.Helper()
+
+	if msg := c.call(command, arguments, body); !msg.Success {
+		c.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+// event 等待名为 name 的事件, 跳过之前的其他事件
+func (c *client) event(name string) *message {
+	c.t.Helper()
+
+	for {
+		var msg *message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Type == "event" && msg.Event == name {
+			return msg
+		}
+	}
+}
+
+// stopped 等待 stopped 事件, 返回暂停的原因和最内层帧
+func (c *client) stopped() (string, StackFrame) {
+	c.t.Helper()
+
+	var ev struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(c.event("stopped").Body, &ev)
+
+	var trace struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+	}
+	c.mustCall("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	if len(trace.StackFrames) == 0 {
+		c.t.Fatal("empty stack trace")
+	}
+	return ev.Reason, trace.StackFrames[0]
+}
+
+func (c *client) evaluate(expression string) string {
+	c.t.Helper()
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	c.mustCall("evaluate", EvaluateArguments{Expression: expression}, &result)
+	return result.Result
+}
+
+const program = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = 1;
+var y = add(x, 2);
+print y;
+`
+
+// launch 启动 source, 在 breakpoints 处设置断点
+func (c *client) launch(source string, stopOnEntry bool, breakpoints ...int) []Breakpoint {
+	c.t.Helper()
+
+	path := c.writeProgram(source)
+
+	c.mustCall("initialize", map[string]interface{}{"adapterID": "lox"}, nil)
+	c.event("initialized")
+	c.mustCall("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+
+	var args SetBreakpointsArguments
+	args.Source.Path = path
+	for _, line := range breakpoints {
+		args.Breakpoints = append(args.Breakpoints, SourceBreakpoint{Line: line})
+	}
+	var result struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	c.mustCall("setBreakpoints", args, &result)
+	c.mustCall("configurationDone", nil, nil)
+	return result.Breakpoints
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c := start(t)
+	breakpoints := c.launch(program, false, 4, 6)
+	if len(breakpoints) != 2 || breakpoints[0].Verified || !breakpoints[1].Verified {
+		t.Errorf("got breakpoints %+v, want line 4 unverified and line 6 verified", breakpoints)
+	}
+
+	if reason, frame := c.stopped(); reason != "breakpoint" || frame.Line != 6 || frame.Name != "<script>" {
+		t.Fatalf("stopped at %s line %d (%s), want breakpoint on line 6", frame.Name, frame.Line, reason)
+	}
+
+	c.mustCall("stepIn", map[string]interface{}{"threadId": threadID}, nil)
+	if reason, frame := c.stopped(); reason != "step" || frame.Line != 2 || frame.Name != "add" {
+		t.Fatalf("stopped at %s line %d (%s) after stepIn, want add line 2", frame.Name, frame.Line, reason)
+	}
+
+	c.mustCall("next", map[string]interface{}{"threadId": threadID}, nil)
+	if _, frame := c.stopped(); frame.Line != 3 {
+		t.Fatalf("stopped on line %d after next, want 3", frame.Line)
+	}
+	if got := c.evaluate("sum * 10"); got != "30" {
+		t.Errorf("evaluate sum * 10 = %q, want 30", got)
+	}
+
+	c.mustCall("stepOut", map[string]interface{}{"threadId": threadID}, nil)
+	if _, frame := c.stopped(); frame.Line != 7 || frame.Name != "<script>" {
+		t.Fatalf("stopped at %s line %d after stepOut, want <script> line 7", frame.Name, frame.Line)
+	}
+	if got := c.evaluate("y"); got != "3" {
+		t.Errorf("evaluate y = %q, want 3", got)
+	}
+
+	c.mustCall("continue", map[string]interface{}{"threadId": threadID}, nil)
+	var output struct {
+		Category string `json:"category"`
+		Output   string `json:"output"`
+	}
+	json.Unmarshal(c.event("output").Body, &output)
+	if output.Category != "stdout" || output.Output != "3\n" {
+		t.Errorf("got output %+v, want 3 on stdout", output)
+	}
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	json.Unmarshal(c.event("exited").Body, &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("got exit code %d, want 0", exited.ExitCode)
+	}
+	c.event("terminated")
+	c.mustCall("disconnect", nil, nil)
+}
+
+func TestStepOver(t *testing.T) {
+	c := start(t)
+	c.launch(program, true)
+
+	if reason, frame := c.stopped(); reason != "entry" || frame.Line != 1 {
+		t.Fatalf("stopped on line %d (%s), want entry on line 1", frame.Line, reason)
+	}
+	for _, want := range []int{5, 6, 7} {
+		c.mustCall("next", map[string]interface{}{"threadId": threadID}, nil)
+		if _, frame := c.stopped(); frame.Line != want || frame.Name != "<script>" {
+			t.Fatalf("stopped at %s line %d after next, want <script> line %d", frame.Name, frame.Line, want)
+		}
+	}
+	c.mustCall("disconnect", nil, nil)
+}
+
+func TestVariables(t *testing.T) {
+	c := start(t)
+	c.launch(program, false, 3)
+	c.stopped()
+
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.mustCall("scopes", ScopesArguments{FrameID: 0}, &scopes)
+	var names []string
+	for _, s := range scopes.Scopes {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ", "); got != "Locals, Globals" {
+		t.Fatalf("got scopes %s, want Locals, Globals", got)
+	}
+
+	var result struct {
+		Variables []Variable `json:"variables"`
+	}
+	c.mustCall("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &result)
+	var vars []string
+	for _, v := range result.Variables {
+		vars = append(vars, v.Name+" = "+v.Value)
+	}
+	if got, want := strings.Join(vars, ", "), "a = 1, b = 2, sum = 3"; got != want {
+		t.Errorf("got locals %s, want %s", got, want)
+	}
+
+	c.mustCall("variables", VariablesArguments{VariablesReference: scopes.Scopes[1].VariablesReference}, &result)
+	globals := map[string]string{}
+	for _, v := range result.Variables {
+		globals[v.Name] = v.Value
+	}
+	if globals["x"] != "1" || globals["add"] != "<fn add>" {
+		t.Errorf("got globals %+v, want x = 1 and add = <fn add>", result.Variables)
+	}
+
+	if msg := c.call("evaluate", EvaluateArguments{Expression: "missing"}, nil); msg.Success || msg.Message != "Undefined variable 'missing'." {
+		t.Errorf("evaluate missing: got success=%v message %q", msg.Success, msg.Message)
+	}
+	if msg := c.call("evaluate", EvaluateArguments{Expression: "1 +"}, nil); msg.Success {
+		t.Error("evaluate 1 + succeeded")
+	}
+	c.mustCall("disconnect", nil, nil)
+}
+
+func TestNotLaunched(t *testing.T) {
+	c := start(t)
+
+	if msg := c.call("next", map[string]interface{}{"threadId": threadID}, nil); msg.Success || msg.Message != errNotLaunched.Error() {
+		t.Errorf("next before launch: got success=%v message %q", msg.Success, msg.Message)
+	}
+	if msg := c.call("launch", LaunchArguments{Program: filepath.Join(t.TempDir(), "missing.lox")}, nil); msg.Success {
+		t.Error("launch of a missing file succeeded")
+	}
+}
+
+// writeProgram 把 source 写到临时文件中, 返回路径
+func (c *client) writeProgram(source string) string {
+	c.t.Helper()
+
+	path := filepath.Join(c.t.TempDir(), "main.lox")
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		c.t.Fatal(err)
+	}
+	return path
+}
+
+// output 等待下一个 category 类别的 output 事件, 返回其中的文本
+func (c *client) output(category string) string {
+	c.t.Helper()
+
+	for {
+		var ev struct {
+			Category string `json:"category"`
+			Output   string `json:"output"`
+		}
+		json.Unmarshal(c.event("output").Body, &ev)
+		if ev.Category == category {
+			return ev.Output
+		}
+	}
+}
+
+// TestLaunchCapabilities launch 参数中的 allow 限制被调试程序的能力, 错误与 lox run 格式相同
+func TestLaunchCapabilities(t *testing.T) {
+	c := start(t)
+
+	c.mustCall("initialize", map[string]interface{}{"adapterID": "lox"}, nil)
+	c.event("initialized")
+	if msg := c.call("launch", LaunchArguments{Program: c.writeProgram("print 1;"), Allow: []string{"bogus"}}, nil); msg.Success {
+		t.Error("launch with an unknown capability succeeded")
+	}
+
+	path := c.writeProgram("print math.abs(-1);\nclock();\n")
+	c.mustCall("launch", LaunchArguments{Program: path, Allow: []string{"fs"}, AllowPaths: []string{"."}}, nil)
+	c.mustCall("configurationDone", nil, nil)
+
+	if out := c.output("stdout"); out != "1\n" {
+		t.Errorf("got stdout %q, want 1", out)
+	}
+	if out := c.output("stderr"); out != "Capability 'time' is not granted.\n[line 2]\n" {
+		t.Errorf("got stderr %q", out)
+	}
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	json.Unmarshal(c.event("exited").Body, &exited)
+	if exited.ExitCode != 70 {
+		t.Errorf("got exit code %d, want 70", exited.ExitCode)
+	}
+}
+
+func TestLaunchSyntaxError(t *testing.T) {
+	c := start(t)
+
+	c.mustCall("initialize", map[string]interface{}{"adapterID": "lox"}, nil)
+	c.event("initialized")
+	if msg := c.call("launch", LaunchArguments{Program: c.writeProgram("print 1;\nprint 1 +;\nprint \"a\\q\";\n")}, nil); msg.Success {
+		t.Error("launch of a program with syntax errors succeeded")
+	}
+	want := []string{"[line 2] Error at ';': Expect expression.\n", "[line 3:9] Error: Invalid escape sequence '\\q'.\n"}
+	got := map[string]bool{c.output("stderr"): true, c.output("stderr"): true}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing diagnostic %q in %v", w, got)
+		}
+	}
+}
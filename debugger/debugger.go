@@ -0,0 +1,280 @@
+package debugger
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+// 暂停的原因
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// 事件类型
+const (
+	EventStopped    = "stopped"
+	EventTerminated = "terminated"
+)
+
+var (
+	// ErrNotPaused 只有暂停时才能单步或者查看变量
+	ErrNotPaused = errors.New("program is not paused")
+	// ErrTerminated 程序已经结束
+	ErrTerminated = errors.New("program has terminated")
+)
+
+type stepMode int
+
+const (
+	modeContinue stepMode = iota
+	modeStepIn
+	modeStepOver
+	modeStepOut
+	modeStop
+)
+
+// Event 调试器发出的事件
+type Event struct {
+	Kind   string
+	Reason string // EventStopped 的原因
+	Line   int
+	Err    error // EventTerminated 时的运行时错误
+}
+
+type command struct {
+	mode    stepMode
+	inspect func(itp *interpreter.Interpreter)
+	done    chan struct{}
+}
+
+// Debugger 通过 interpreter.Hook 控制解释器的执行
+type Debugger struct {
+	itp *interpreter.Interpreter
+
+	mu             sync.Mutex
+	breakpoints    map[int]bool
+	pauseRequested bool
+	paused         bool
+	stopping       bool
+	terminated     bool
+
+	// 只在主任务的 goroutine 中访问, spawn 出的任务不会暂停
+	mode       stepMode
+	depth      int
+	inspecting bool
+
+	commands chan command
+	events   chan Event
+}
+
+// errStopped 用于在 Stop 之后展开解释器的调用栈
+var errStopped = errors.New("debugger stopped")
+
+// New 创建调试器并把它设置为 itp 的钩子
+func New(itp *interpreter.Interpreter) *Debugger {
+	d := &Debugger{
+		itp:         itp,
+		breakpoints: make(map[int]bool),
+		commands:    make(chan command),
+		events:      make(chan Event, 16),
+	}
+	itp.SetHook(d)
+	return d
+}
+
+// Events 暂停和结束事件
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+// SetBreakpoints 替换所有行断点
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = make(map[int]bool, len(lines))
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// Run 在新的 goroutine 中执行程序, stopOnEntry 时在第一条语句前暂停
+func (d *Debugger) Run(statements []expr.Stmt, stopOnEntry bool) {
+	if stopOnEntry {
+		d.mode = modeStepIn
+	}
+
+	go func() {
+		err := d.itp.Run(statements)
+		if err == errStopped {
+			err = nil
+		}
+
+		d.mu.Lock()
+		d.terminated = true
+		d.mu.Unlock()
+		d.events <- Event{Kind: EventTerminated, Err: err}
+		close(d.events)
+	}()
+}
+
+// BeforeExecute 实现 interpreter.Hook
+//
+// 单步和断点只作用于主任务; spawn 出的任务与主任务并发执行, 只在 Stop 之后结束
+func (d *Debugger) BeforeExecute(itp *interpreter.Interpreter, stmt expr.Stmt) {
+	d.mu.Lock()
+	stopping := d.stopping
+	d.mu.Unlock()
+	if stopping {
+		panic(errStopped)
+	}
+
+	if itp.Task().Spawned() {
+		return
+	}
+	if _, ok := stmt.(*expr.Block); ok || d.inspecting {
+		return
+	}
+
+	reason := d.stopReason(itp, stmt)
+	if reason == "" {
+		return
+	}
+
+	d.mu.Lock()
+	d.paused = true
+	d.pauseRequested = false
+	d.mu.Unlock()
+
+	d.events <- Event{Kind: EventStopped, Reason: reason, Line: stmt.Line()}
+
+	for cmd := range d.commands {
+		if cmd.inspect != nil {
+			d.inspect(itp, cmd)
+			continue
+		}
+
+		d.mu.Lock()
+		d.paused = false
+		d.mu.Unlock()
+		close(cmd.done)
+
+		if cmd.mode == modeStop {
+			panic(errStopped)
+		}
+		d.mode = cmd.mode
+		d.depth = itp.Depth()
+		return
+	}
+}
+
+// inspect 执行 Inspect 的回调, 期间不会再次暂停
+func (d *Debugger) inspect(itp *interpreter.Interpreter, cmd command) {
+	d.inspecting = true
+	defer func() {
+		d.inspecting = false
+		close(cmd.done)
+	}()
+
+	cmd.inspect(itp)
+}
+
+func (d *Debugger) stopReason(itp *interpreter.Interpreter, stmt expr.Stmt) string {
+	d.mu.Lock()
+	pause, breakpoint := d.pauseRequested, d.breakpoints[stmt.Line()]
+	d.mu.Unlock()
+
+	if pause {
+		return ReasonPause
+	}
+
+	depth := itp.Depth()
+	switch d.mode {
+	case modeStepIn:
+		if d.depth == 0 {
+			return ReasonEntry
+		}
+		return ReasonStep
+	case modeStepOver:
+		if depth <= d.depth {
+			return ReasonStep
+		}
+	case modeStepOut:
+		if depth < d.depth {
+			return ReasonStep
+		}
+	}
+
+	if breakpoint {
+		return ReasonBreakpoint
+	}
+	return ""
+}
+
+// Continue 继续执行直到断点
+func (d *Debugger) Continue() error {
+	return d.send(command{mode: modeContinue})
+}
+
+// StepOver 执行到同一帧或者外层帧的下一条语句
+func (d *Debugger) StepOver() error {
+	return d.send(command{mode: modeStepOver})
+}
+
+// StepIn 执行到下一条语句, 会进入函数调用
+func (d *Debugger) StepIn() error {
+	return d.send(command{mode: modeStepIn})
+}
+
+// StepOut 执行到当前函数返回之后
+func (d *Debugger) StepOut() error {
+	return d.send(command{mode: modeStepOut})
+}
+
+// Pause 在下一条语句之前暂停
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pauseRequested = true
+	d.mu.Unlock()
+}
+
+// Stop 结束被暂停的程序; 正在运行的程序会在下一条语句前结束
+func (d *Debugger) Stop() {
+	d.mu.Lock()
+	d.stopping = true
+	paused := d.paused
+	d.mu.Unlock()
+
+	if paused {
+		d.send(command{mode: modeStop})
+	}
+}
+
+// Inspect 在暂停的解释器 goroutine 中执行 fn, 用于查看调用栈和变量以及求值
+func (d *Debugger) Inspect(fn func(itp *interpreter.Interpreter)) error {
+	return d.send(command{inspect: fn})
+}
+
+func (d *Debugger) send(cmd command) error {
+	d.mu.Lock()
+	paused, terminated := d.paused, d.terminated
+	d.mu.Unlock()
+
+	if terminated {
+		return ErrTerminated
+	}
+	if !paused {
+		return ErrNotPaused
+	}
+
+	cmd.done = make(chan struct{})
+	d.commands <- cmd
+	<-cmd.done
+	return nil
+}
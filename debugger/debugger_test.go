@@ -0,0 +1,234 @@
+package debugger_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zhiruchen/lox-go/debugger"
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+const program = `fun countdown(n) {
+  while (n > 0) {
+    n = n - 1;
+  }
+  return n;
+}
+var a = countdown(2);
+print a;
+`
+
+func parse(t *testing.T, source string) []expr.Stmt {
+	t.Helper()
+
+	p := parser.NewParser(scanner.NewScanner(source).ScanTokens(), func(tk *token.Token, msg string) {
+		t.Fatalf("line %d: %s", tk.Line, msg)
+	})
+	return p.Parse()
+}
+
+// start 运行 source, 返回调试器和 print 的输出
+func start(t *testing.T, source string, stopOnEntry bool, breakpoints ...int) (*debugger.Debugger, *bytes.Buffer) {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(out)
+	d := debugger.New(itp)
+	d.SetBreakpoints(breakpoints)
+	d.Run(parse(t, source), stopOnEntry)
+	return d, out
+}
+
+func next(t *testing.T, d *debugger.Debugger) debugger.Event {
+	t.Helper()
+
+	select {
+	case ev, ok := <-d.Events():
+		if !ok {
+			t.Fatal("events closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return debugger.Event{}
+}
+
+// expectStop 等待暂停事件并检查原因和行号
+func expectStop(t *testing.T, d *debugger.Debugger, reason string, line int) {
+	t.Helper()
+
+	ev := next(t, d)
+	if ev.Kind != debugger.EventStopped || ev.Reason != reason || ev.Line != line {
+		t.Fatalf("got %+v, want %s on line %d", ev, reason, line)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d, out := start(t, program, true)
+	expectStop(t, d, debugger.ReasonEntry, 1)
+
+	steps := []struct {
+		step   func() error
+		reason string
+		line   int
+	}{
+		{d.StepOver, debugger.ReasonStep, 7},
+		{d.StepIn, debugger.ReasonStep, 2},
+		{d.StepIn, debugger.ReasonStep, 3},
+		{d.StepOver, debugger.ReasonStep, 3},
+		{d.StepOut, debugger.ReasonStep, 8},
+	}
+	for _, s := range steps {
+		if err := s.step(); err != nil {
+			t.Fatal(err)
+		}
+		expectStop(t, d, s.reason, s.line)
+	}
+
+	var a string
+	err := d.Inspect(func(itp *interpreter.Interpreter) {
+		v, err := itp.Evaluate(expr.NewVariable(&token.Token{TokenType: token.Identifier, Lexeme: "a", Line: 8}), itp.Env())
+		if err != nil {
+			t.Error(err)
+		}
+		a = itp.Stringify(v)
+	})
+	if err != nil || a != "0" {
+		t.Errorf("inspect a: got %q, %v, want 0", a, err)
+	}
+
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(t, d); ev.Kind != debugger.EventTerminated || ev.Err != nil {
+		t.Errorf("got %+v, want terminated without error", ev)
+	}
+	if out.String() != "0\n" {
+		t.Errorf("got output %q, want 0", out.String())
+	}
+	if err := d.Continue(); err != debugger.ErrTerminated {
+		t.Errorf("Continue after termination: got %v, want %v", err, debugger.ErrTerminated)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d, _ := start(t, program, false, 3)
+
+	// while 循环两次经过第 3 行的断点
+	for i := 0; i < 2; i++ {
+		expectStop(t, d, debugger.ReasonBreakpoint, 3)
+
+		var frames []interpreter.Frame
+		d.Inspect(func(itp *interpreter.Interpreter) { frames = itp.Frames() })
+		if len(frames) != 2 || frames[1].Name != "countdown" {
+			t.Fatalf("got frames %+v, want <script> and countdown", frames)
+		}
+		if err := d.Continue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ev := next(t, d); ev.Kind != debugger.EventTerminated {
+		t.Errorf("got %+v, want terminated", ev)
+	}
+}
+
+func TestStop(t *testing.T) {
+	d, out := start(t, program, false, 7)
+	expectStop(t, d, debugger.ReasonBreakpoint, 7)
+
+	if err := d.StepIn(); err != nil {
+		t.Fatal(err)
+	}
+	expectStop(t, d, debugger.ReasonStep, 2)
+	d.Stop()
+
+	if ev := next(t, d); ev.Kind != debugger.EventTerminated || ev.Err != nil {
+		t.Errorf("got %+v, want terminated without error", ev)
+	}
+	if out.Len() != 0 {
+		t.Errorf("got output %q after Stop", out.String())
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	d, _ := start(t, "print 1;\nprint missing;\n", false)
+
+	ev := next(t, d)
+	if ev.Kind != debugger.EventTerminated || ev.Err == nil || ev.Err.Error() != "Undefined variable 'missing'." {
+		t.Errorf("got %+v, want terminated with an undefined variable error", ev)
+	}
+	if err := d.StepOver(); err != debugger.ErrTerminated {
+		t.Errorf("StepOver after termination: got %v, want %v", err, debugger.ErrTerminated)
+	}
+}
+
+func TestLines(t *testing.T) {
+	lines := debugger.Lines(parse(t, program))
+	for _, line := range []int{1, 2, 3, 5, 7, 8} {
+		if !lines[line] {
+			t.Errorf("line %d has a statement", line)
+		}
+	}
+	for _, line := range []int{4, 6, 9} {
+		if lines[line] {
+			t.Errorf("line %d has no statement", line)
+		}
+	}
+}
+
+const spawnProgram = `fun work(n) {
+  var total = 0;
+  for (var i in range(n)) total += i;
+  return total;
+}
+spawn work(200);
+spawn work(200);
+var a = work(3);
+print a;
+`
+
+// TestSpawnedTasks 在 go test -race 下运行: spawn 出的任务与主任务并发调用钩子,
+// 只有主任务会暂停
+func TestSpawnedTasks(t *testing.T) {
+	d, out := start(t, spawnProgram, true, 4)
+	expectStop(t, d, debugger.ReasonEntry, 1)
+
+	var lines []int
+	for {
+		if err := d.StepOver(); err != nil {
+			t.Fatal(err)
+		}
+		ev := next(t, d)
+		if ev.Kind == debugger.EventTerminated {
+			if ev.Err != nil {
+				t.Fatal(ev.Err)
+			}
+			break
+		}
+		lines = append(lines, ev.Line)
+		if ev.Reason == debugger.ReasonBreakpoint {
+			// 断点只在主任务调用 work(3) 时命中一次
+			if err := d.StepOut(); err != nil {
+				t.Fatal(err)
+			}
+			expectStop(t, d, debugger.ReasonStep, 9)
+			lines = append(lines, 9)
+		}
+	}
+
+	if want := "[6 7 8 4 9]"; fmt.Sprint(lines) != want {
+		t.Errorf("stopped on lines %v, want %s", lines, want)
+	}
+	if out.String() != "3\n" {
+		t.Errorf("got output %q, want 3", out.String())
+	}
+}
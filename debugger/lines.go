@@ -0,0 +1,45 @@
+package debugger
+
+import (
+	"github.com/zhiruchen/lox-go/expr"
+)
+
+// Lines 可以设置断点的行, 即有语句开始的行
+func Lines(statements []expr.Stmt) map[int]bool {
+	lines := make(map[int]bool)
+	collectLines(statements, lines)
+	return lines
+}
+
+func collectLines(statements []expr.Stmt, lines map[int]bool) {
+	for _, stmt := range statements {
+		if stmt == nil {
+			continue
+		}
+
+		switch s := stmt.(type) {
+		case *expr.Block:
+			collectLines(s.Statements, lines)
+			continue
+		case *expr.Function:
+			collectLines(s.Body, lines)
+		case *expr.Test:
+			collectLines(s.Body, lines)
+		case *expr.IF:
+			collectLines([]expr.Stmt{s.ThenBranch, s.ElseBranch}, lines)
+		case *expr.While:
+			collectLines([]expr.Stmt{s.Body}, lines)
+		case *expr.ForIn:
+			collectLines([]expr.Stmt{s.Body}, lines)
+		case *expr.Select:
+			for _, c := range s.Cases {
+				collectLines([]expr.Stmt{c.Body}, lines)
+			}
+		case *expr.Match:
+			for _, c := range s.Cases {
+				collectLines([]expr.Stmt{c.Body}, lines)
+			}
+		}
+		lines[stmt.Line()] = true
+	}
+}
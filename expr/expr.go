@@ -13,6 +13,13 @@ type Visitor interface {
 	VisitorVariableExpr(expr *Variable) interface{}
 	VisitorAssignExpr(expr *Assign) interface{}
 	VisitorCallExpr(expr *Call) interface{}
+	VisitorInterpolationExpr(expr *Interpolation) interface{}
+	VisitorUpdateExpr(expr *Update) interface{}
+	VisitorConditionalExpr(expr *Conditional) interface{}
+	VisitorListExpr(expr *List) interface{}
+	VisitorGetExpr(expr *Get) interface{}
+	VisitorSetExpr(expr *Set) interface{}
+	VisitorMapExpr(expr *Map) interface{}
 
 	StmtVisitor
 }
@@ -34,6 +41,23 @@ func (as *Assign) Accept(v Visitor) interface{} {
 	return v.VisitorAssignExpr(as)
 }
 
+// Update ++x, --x, x++, x--; 前缀形式的值为修改后的值, 后缀形式为修改前的值.
+// Object 不为 nil 时目标是属性 Object.Name, Object 只求值一次
+type Update struct {
+	Object   Expr
+	Name     *token.Token
+	Operator *token.Token
+	Prefix   bool
+}
+
+func NewUpdate(name, operator *token.Token, prefix bool) *Update {
+	return &Update{Name: name, Operator: operator, Prefix: prefix}
+}
+
+func (u *Update) Accept(v Visitor) interface{} {
+	return v.VisitorUpdateExpr(u)
+}
+
 type Binary struct {
 	Left     Expr
 	Operator *token.Token
@@ -66,6 +90,19 @@ func (cl *Call) Accept(v Visitor) interface{} {
 	return v.VisitorCallExpr(cl)
 }
 
+// Interpolation 插值字符串, 各部分转换为字符串后拼接
+type Interpolation struct {
+	Parts []Expr
+}
+
+func NewInterpolation(parts []Expr) *Interpolation {
+	return &Interpolation{Parts: parts}
+}
+
+func (in *Interpolation) Accept(v Visitor) interface{} {
+	return v.VisitorInterpolationExpr(in)
+}
+
 type Grouping struct {
 	Expression Expr
 }
@@ -78,6 +115,65 @@ func (g *Grouping) Accept(v Visitor) interface{} {
 	return v.VisitorGroupingExpr(g)
 }
 
+// Get object.name
+type Get struct {
+	Object Expr
+	Name   *token.Token
+}
+
+func NewGet(object Expr, name *token.Token) *Get {
+	return &Get{Object: object, Name: name}
+}
+
+func (g *Get) Accept(v Visitor) interface{} {
+	return v.VisitorGetExpr(g)
+}
+
+// Set object.name = value; Operator 不为 nil 时是复合赋值 object.name op= value, object 只求值一次
+type Set struct {
+	Object   Expr
+	Name     *token.Token
+	Value    Expr
+	Operator *token.Token
+}
+
+func NewSet(object Expr, name *token.Token, value Expr) *Set {
+	return &Set{Object: object, Name: name, Value: value}
+}
+
+func (s *Set) Accept(v Visitor) interface{} {
+	return v.VisitorSetExpr(s)
+}
+
+// List [a, b, c]
+type List struct {
+	Bracket  *token.Token
+	Elements []Expr
+}
+
+func NewList(bracket *token.Token, elements []Expr) *List {
+	return &List{Bracket: bracket, Elements: elements}
+}
+
+func (l *List) Accept(v Visitor) interface{} {
+	return v.VisitorListExpr(l)
+}
+
+// Map {key: value, ...}, 键可以是任意表达式; 语句开头的 { 是代码块
+type Map struct {
+	Brace  *token.Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func NewMap(brace *token.Token, keys, values []Expr) *Map {
+	return &Map{Brace: brace, Keys: keys, Values: values}
+}
+
+func (m *Map) Accept(v Visitor) interface{} {
+	return v.VisitorMapExpr(m)
+}
+
 type Literal struct {
 	Value interface{}
 }
@@ -90,6 +186,22 @@ func (l *Literal) Accept(v Visitor) interface{} {
 	return v.VisitorLiteralExpr(l)
 }
 
+// Conditional cond ? then : else
+type Conditional struct {
+	Condition Expr
+	Then      Expr
+	Else      Expr
+	Question  *token.Token
+}
+
+func NewConditional(cond, thenBranch, elseBranch Expr, question *token.Token) *Conditional {
+	return &Conditional{Condition: cond, Then: thenBranch, Else: elseBranch, Question: question}
+}
+
+func (c *Conditional) Accept(v Visitor) interface{} {
+	return v.VisitorConditionalExpr(c)
+}
+
 type Logical struct {
 	Left     Expr
 	Right    Expr
@@ -0,0 +1,45 @@
+package expr
+
+import (
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Pattern match 语句中 case 的模式
+type Pattern interface {
+	pattern()
+}
+
+// LiteralPattern 与字面量相等时匹配, Value 为 Literal 或者负数的 Unary
+type LiteralPattern struct {
+	Value Expr
+}
+
+// WildcardPattern _ 匹配任意值
+type WildcardPattern struct {
+	Token *token.Token
+}
+
+// BindingPattern 匹配任意值, 并在 case 的作用域中定义变量 Name
+type BindingPattern struct {
+	Name *token.Token
+}
+
+// ListPattern 匹配长度相同并且每个元素都匹配的列表
+type ListPattern struct {
+	Bracket  *token.Token
+	Elements []Pattern
+}
+
+// ShapePattern {name: pattern, ...} 匹配有这些属性并且属性的值都匹配的映射或者内置对象,
+// {name} 是 {name: name} 的简写
+type ShapePattern struct {
+	Brace  *token.Token
+	Names  []*token.Token
+	Fields []Pattern
+}
+
+func (*LiteralPattern) pattern()  {}
+func (*WildcardPattern) pattern() {}
+func (*BindingPattern) pattern()  {}
+func (*ListPattern) pattern()     {}
+func (*ShapePattern) pattern()    {}
@@ -13,48 +13,150 @@ type StmtVisitor interface {
 	VisitorBlockStmtExpr(expr *Block) interface{}
 	VisitorIFStmtExpr(expr *IF) interface{}
 	VisitorFunStmtExpr(expr *Function) interface{}
+	VisitorAssertStmtExpr(expr *Assert) interface{}
+	VisitorTestStmtExpr(expr *Test) interface{}
+	VisitorMatchStmtExpr(expr *Match) interface{}
+	VisitorForInStmtExpr(expr *ForIn) interface{}
+	VisitorYieldStmtExpr(expr *Yield) interface{}
+	VisitorSpawnStmtExpr(expr *Spawn) interface{}
+	VisitorSelectStmtExpr(expr *Select) interface{}
 }
 
 type Stmt interface {
 	Accept(v Visitor) interface{}
+	Line() int
+	SetLine(line int)
+}
+
+// Pos 语句开始的行号, 由 parser 设置
+type Pos struct {
+	line int
+}
+
+func (p *Pos) Line() int {
+	return p.line
+}
+
+func (p *Pos) SetLine(line int) {
+	p.line = line
 }
 
 type Expression struct {
+	Pos
 	Expression Expr
 }
 
 type Function struct {
+	Pos
 	Name       *token.Token
 	Parameters []*token.Token
 	Body       []Stmt
+	Generator  bool // 函数体中有 yield, 调用时返回生成器
 }
 
 type IF struct {
+	Pos
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
 }
 
 type Print struct {
+	Pos
 	Print Expr
 }
 
 type Return struct {
+	Pos
+	Keyword *token.Token
+	Value   Expr
+}
+
+// Yield yield value; 挂起生成器, value 为 nil 时产生 nil
+type Yield struct {
+	Pos
 	Keyword *token.Token
 	Value   Expr
 }
 
 type Var struct {
+	Pos
 	Name        *token.Token
 	Initializer Expr
 }
 
 type While struct {
+	Pos
 	Condition Expr
 	Body      Stmt
 }
 
+// ForIn for (var Name in Iterable) Body
+type ForIn struct {
+	Pos
+	Name     *token.Token
+	In       *token.Token
+	Iterable Expr
+	Body     Stmt
+}
+
+// Spawn spawn f(args); 在当前任务中对 f 和参数求值, 在新的任务中调用
+type Spawn struct {
+	Pos
+	Keyword *token.Token
+	Call    *Call
+}
+
+// Select select { case var x = ch.recv() => ... case ch.send(v) => ... case _ => ... }
+type Select struct {
+	Pos
+	Keyword *token.Token
+	Cases   []*SelectCase
+}
+
+// SelectCase Operation 为 recv 或 send 的 token, 为 nil 时是 case _, 在没有就绪的操作时执行
+type SelectCase struct {
+	Keyword   *token.Token
+	Name      *token.Token // var Name = ch.recv(), 可以为 nil
+	Channel   Expr
+	Operation *token.Token
+	Value     Expr // send 的参数
+	Body      Stmt
+}
+
+// Assert assert condition, message;
+type Assert struct {
+	Pos
+	Keyword   *token.Token
+	Condition Expr
+	Message   Expr // 可以为 nil
+}
+
+// Test test "name" { ... }, 只由测试运行器执行
+type Test struct {
+	Pos
+	Name *token.Token
+	Body []Stmt
+}
+
+// Match match (value) { case pattern, ... if guard => statement ... }
+type Match struct {
+	Pos
+	Keyword *token.Token
+	Value   Expr
+	Cases   []*Case
+}
+
+// Case 任一模式匹配且 Guard 为真时执行 Body
+type Case struct {
+	Keyword  *token.Token
+	Patterns []Pattern
+	Guard    Expr // 可以为 nil
+	Body     Stmt
+}
+
 type Block struct {
+	Pos
 	Statements []Stmt
 }
 
@@ -78,6 +180,10 @@ func (st *Return) Accept(v Visitor) interface{} {
 	return v.VisitorReturnStmtExpr(st)
 }
 
+func (st *Yield) Accept(v Visitor) interface{} {
+	return v.VisitorYieldStmtExpr(st)
+}
+
 func (st *Var) Accept(v Visitor) interface{} {
 	return v.VisitorVarStmtExpr(st)
 }
@@ -90,6 +196,30 @@ func (st *Block) Accept(v Visitor) interface{} {
 	return v.VisitorBlockStmtExpr(st)
 }
 
+func (st *Assert) Accept(v Visitor) interface{} {
+	return v.VisitorAssertStmtExpr(st)
+}
+
+func (st *Test) Accept(v Visitor) interface{} {
+	return v.VisitorTestStmtExpr(st)
+}
+
+func (st *ForIn) Accept(v Visitor) interface{} {
+	return v.VisitorForInStmtExpr(st)
+}
+
+func (st *Spawn) Accept(v Visitor) interface{} {
+	return v.VisitorSpawnStmtExpr(st)
+}
+
+func (st *Select) Accept(v Visitor) interface{} {
+	return v.VisitorSelectStmtExpr(st)
+}
+
+func (st *Match) Accept(v Visitor) interface{} {
+	return v.VisitorMatchStmtExpr(st)
+}
+
 func NewPrintStmt(e Expr) *Print {
 	return &Print{Print: e}
 }
@@ -98,6 +228,10 @@ func NewReturnStmt(keyword *token.Token, value Expr) *Return {
 	return &Return{Keyword: keyword, Value: value}
 }
 
+func NewYieldStmt(keyword *token.Token, value Expr) *Yield {
+	return &Yield{Keyword: keyword, Value: value}
+}
+
 func NewExpressionStmt(e Expr) *Expression {
 	return &Expression{Expression: e}
 }
@@ -118,6 +252,34 @@ func NewWhileStmt(cond Expr, body Stmt) *While {
 	return &While{Condition: cond, Body: body}
 }
 
+func NewForInStmt(name, in *token.Token, iterable Expr, body Stmt) *ForIn {
+	return &ForIn{Name: name, In: in, Iterable: iterable, Body: body}
+}
+
 func NewBlockStmt(stmts []Stmt) *Block {
 	return &Block{Statements: stmts}
 }
+
+func NewAssertStmt(keyword *token.Token, cond, message Expr) *Assert {
+	return &Assert{Keyword: keyword, Condition: cond, Message: message}
+}
+
+func NewTestStmt(name *token.Token, body []Stmt) *Test {
+	return &Test{Name: name, Body: body}
+}
+
+func NewMatchStmt(keyword *token.Token, value Expr, cases []*Case) *Match {
+	return &Match{Keyword: keyword, Value: value, Cases: cases}
+}
+
+func NewCase(keyword *token.Token, patterns []Pattern, guard Expr, body Stmt) *Case {
+	return &Case{Keyword: keyword, Patterns: patterns, Guard: guard, Body: body}
+}
+
+func NewSpawnStmt(keyword *token.Token, call *Call) *Spawn {
+	return &Spawn{Keyword: keyword, Call: call}
+}
+
+func NewSelectStmt(keyword *token.Token, cases []*SelectCase) *Select {
+	return &Select{Keyword: keyword, Cases: cases}
+}
@@ -0,0 +1,64 @@
+package fuzz_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhiruchen/lox-go/conformance"
+	"github.com/zhiruchen/lox-go/fuzz"
+)
+
+// seed 语料为内置的 Seeds 和 testdata/conformance 中的所有程序
+func seed(f *testing.F) {
+	for _, s := range fuzz.Seeds {
+		f.Add([]byte(s))
+	}
+
+	files, err := conformance.Discover(filepath.Join("..", "testdata", "conformance"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+func FuzzScanner(f *testing.F) {
+	seed(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzz.CheckScanner(data)
+	})
+}
+
+func FuzzParser(f *testing.F) {
+	seed(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzz.CheckParser(data)
+	})
+}
+
+// FuzzInterpreter 每个输入最多执行 fuzz.StepLimit 条语句, 并且没有任何能力
+func FuzzInterpreter(f *testing.F) {
+	seed(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzz.CheckInterpreter(data)
+	})
+}
+
+// TestMutator lox-go fuzz 使用的变异器在种子上运行一小段时间, 不应该发现 panic
+func TestMutator(t *testing.T) {
+	f := fuzz.NewFuzzer(fuzz.CheckParser, nil)
+	f.Run(time.Now().Add(200 * time.Millisecond))
+	if f.Execs < len(fuzz.Seeds) {
+		t.Fatalf("ran %d inputs, want at least the %d seeds", f.Execs, len(fuzz.Seeds))
+	}
+	for _, c := range f.Crashes {
+		t.Errorf("panic %v on input %q", c.Panic, c.Input)
+	}
+}
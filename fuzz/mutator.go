@@ -0,0 +1,167 @@
+package fuzz
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// tokens 变异时插入的片段
+var tokens = []string{
+	"(", ")", "{", "}", ",", ".", "-", "+", ";", "*", "/", "~/", "%", "**", "&", "|", "^", "~", "<<", ">>", "+=", "-=", "*=", "/=", "%=", "++", "--", "?", ":", "??", "[", "]", "=>", "!", "!=", "=", "==", "<", "<=", ">", ">=",
+	"and", "or", "if", "else", "for", "while", "fun", "return", "var", "print", "assert", "test", "match", "case", "_", "in", "range", "yield", ".next()", ".close()", ".done", "spawn", "select", "channel()", ".send(1)", ".recv()", ".get(", ".set(", ".keys()", "iterator", "math.", "math.pow(", "math.min(", ".len()", ".substring(", ".split(", ".repeat(", ".toString(", "path.join(", "fs.readFile(", "isError(", ".message", "nil",
+	"true", "false", "class", "this", "super", "\"", "\"s\"", "\"\"\"", "`", "\\", "\\u{", "${", "1", "0.5", "0x", "0b", "_", "e", "n", "m", "x", "f()", "//", "/*", "*/", "\n",
+}
+
+// Crash 导致目标 panic 的输入
+type Crash struct {
+	Input []byte
+	Panic interface{}
+	Stack []byte
+}
+
+// Fuzzer 在种子上随机变异并运行目标, 记录导致 panic 的输入
+//
+// 这是 lox-go fuzz 子命令使用的简单变异器, 不需要 go 工具链; go test -fuzz 使用 go 自带的变异器, 见 fuzz_test.go
+type Fuzzer struct {
+	Target  Target
+	Execs   int
+	Crashes []*Crash // 每种 panic 只记录第一个输入
+
+	corpus [][]byte
+	rand   *rand.Rand
+	seen   map[string]bool
+}
+
+// NewFuzzer seeds 为初始语料, 为空时使用内置的 Seeds
+func NewFuzzer(target Target, seeds [][]byte) *Fuzzer {
+	if len(seeds) == 0 {
+		for _, s := range Seeds {
+			seeds = append(seeds, []byte(s))
+		}
+	}
+	return &Fuzzer{
+		Target: target,
+		corpus: seeds,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		seen:   make(map[string]bool),
+	}
+}
+
+// LoadSeeds 读取 dir 下所有 .lox 文件
+func LoadSeeds(dir string) ([][]byte, error) {
+	var seeds [][]byte
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".lox" {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		seeds = append(seeds, data)
+		return err
+	})
+	return seeds, err
+}
+
+// Run 运行到 deadline 为止, 先运行所有种子再运行变异后的输入
+func (f *Fuzzer) Run(deadline time.Time) {
+	for _, seed := range f.corpus {
+		f.exec(seed)
+	}
+
+	for time.Now().Before(deadline) {
+		input := f.mutate(f.corpus[f.rand.Intn(len(f.corpus))])
+		if f.exec(input) == 1 && len(f.corpus) < 10000 {
+			f.corpus = append(f.corpus, input)
+		}
+	}
+}
+
+func (f *Fuzzer) exec(input []byte) (result int) {
+	f.Execs++
+	defer func() {
+		if r := recover(); r != nil {
+			result = -1
+			if key := fmt.Sprint(r); !f.seen[key] {
+				f.seen[key] = true
+				f.Crashes = append(f.Crashes, &Crash{Input: input, Panic: r, Stack: debug.Stack()})
+			}
+		}
+	}()
+	return f.Target(input)
+}
+
+// mutate 对 input 的拷贝做 1 到 4 次随机修改
+func (f *Fuzzer) mutate(input []byte) []byte {
+	data := append([]byte{}, input...)
+
+	for n := 1 + f.rand.Intn(4); n > 0; n-- {
+		pos := 0
+		if len(data) > 0 {
+			pos = f.rand.Intn(len(data))
+		}
+
+		switch f.rand.Intn(5) {
+		case 0: // 替换一个字节
+			if len(data) > 0 {
+				data[pos] = byte(f.rand.Intn(128))
+			}
+		case 1: // 插入一个片段
+			tk := tokens[f.rand.Intn(len(tokens))]
+			data = append(data[:pos], append([]byte(tk), data[pos:]...)...)
+		case 2: // 删除一段
+			if len(data) > 0 {
+				end := pos + 1 + f.rand.Intn(min(8, len(data)-pos))
+				data = append(data[:pos], data[end:]...)
+			}
+		case 3: // 复制一段
+			if len(data) > 0 {
+				end := pos + 1 + f.rand.Intn(min(32, len(data)-pos))
+				chunk := append([]byte{}, data[pos:end]...)
+				at := f.rand.Intn(len(data) + 1)
+				data = append(data[:at], append(chunk, data[at:]...)...)
+			}
+		case 4: // 拼接另一个输入的片段
+			other := f.corpus[f.rand.Intn(len(f.corpus))]
+			if len(other) > 0 {
+				start := f.rand.Intn(len(other))
+				chunk := other[start : start+1+f.rand.Intn(len(other)-start)]
+				data = append(data[:pos], append(append([]byte{}, chunk...), data[pos:]...)...)
+			}
+		}
+	}
+	return data
+}
+
+// WriteCrashes 把每个导致 panic 的输入写到 dir 下, 文件名为内容的 sha1
+func (f *Fuzzer) WriteCrashes(dir string) error {
+	if len(f.Crashes) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, c := range f.Crashes {
+		name := filepath.Join(dir, fmt.Sprintf("%x", sha1.Sum(c.Input)))
+		if err := ioutil.WriteFile(name+".lox", c.Input, 0644); err != nil {
+			return err
+		}
+		report := fmt.Sprintf("panic: %v\n\n%s", c.Panic, c.Stack)
+		if err := ioutil.WriteFile(name+".txt", []byte(report), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,27 @@
+package fuzz
+
+// Seeds 内置的种子程序, 覆盖所有语法结构
+var Seeds = []string{
+	`print 1 + 2 * 3 - 4 / 2;`,
+	`print "a" + "b"; print !true; print -1; print nil == false;`,
+	`var a = 1; { var a = 2; print a; } print a; a = 3;`,
+	`if (1 < 2) print "yes"; else print "no"; print nil or 1 and 2;`,
+	`var i = 0; while (i < 3) { print i; i = i + 1; }`,
+	`var j = 1; j += 2; j *= 3; print j++; print --j;`,
+	`var k = nil ?? 1; print k > 0 ? "pos" : k == 0 ? "zero" : "neg";`,
+	`match ([1, [2]]) { case 0, -1 => print 0; case [x, [y]] if x < y => print x; case _ => { print nil; } }`,
+	`match ({"p": {"x": 1}}) { case {p: {x, y}} => print y; case {p: {x: 1}} => print x; case {} => print 0; }`,
+	`for (var i in range(0, 6, 2)) for (var c in "ab") print c; for (var x in [1, 2]) print x;`,
+	`var m = {"a": 1, 2: [3]}; m.b = m.a; m.set("c", 0); for (var k in m) print k; print m.keys(); fun it() { return {"next": clock, "done": true}; } for (var x in {"iterator": it}) print x;`,
+	`fun gen(n) { for (var i in range(n)) yield i; } var g = gen(3); print g.next(); for (var x in g) print x; g.close(); print g.done;`,
+	`var ch = channel(1); fun w(c) { c.send(1); } spawn w(ch); select { case var x = ch.recv() => print x; case ch.send(2) => print 2; case _ => print nil; }`,
+	`print math.max(1, 2.5, 3n) + math.pow(2, 10) + math.floor(-2.5m) + math.sqrt(2) * math.pi; print math.isNan(math.nan);`,
+	`var s = "héllo"; print s.len() + s.indexOf("l"); print ",".join(s.substring(1).upper().chars()); print "1.5".parseNumber().toString(2);`,
+	`for (var i = 0; i < 3; i = i + 1) print i; for (;;) return;`,
+	`fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(10);`,
+	`fun make() { var i = 0; fun inc() { i = i + 1; return i; } return inc; } var c = make(); print c(); print clock;`,
+	`assert 1 == 1, "message"; test "name" { assert true; }`,
+	`/* block /* nested */ comment */ // line comment
+print "multi
+line";`,
+}
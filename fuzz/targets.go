@@ -0,0 +1,130 @@
+// Package fuzz 扫描器, 语法分析器和解释器的模糊测试目标
+//
+// CheckScanner, CheckParser 和 CheckInterpreter 检查一个输入, panic 就表示发现了问题;
+// 任何输入都只能产生诊断信息或者结果. fuzz_test.go 中的 FuzzScanner 等用 go test -fuzz 运行它们,
+// lox-go fuzz 子命令用 mutator.go 中内置的变异器运行它们.
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// StepLimit CheckInterpreter 中每个输入最多执行的语句数
+const StepLimit = 10000
+
+// Target 目标函数: 1 表示输入有意义, 0 表示没有, -1 表示不要加入语料
+type Target func(data []byte) int
+
+// Targets 按名字索引的所有目标
+var Targets = map[string]Target{
+	"scanner":     CheckScanner,
+	"parser":      CheckParser,
+	"interpreter": CheckInterpreter,
+}
+
+// CheckScanner 扫描结果必须以 Eof 结尾, 位置从 1 开始并且行号不会减小
+func CheckScanner(data []byte) int {
+	errors := 0
+	s := scanner.NewScanner(string(data))
+	s.SetErrFunc(func(line, column int, msg string) {
+		if line < 1 || column < 1 {
+			panic(fmt.Sprintf("bad error position %d:%d for %q", line, column, msg))
+		}
+		errors++
+	})
+	tokens := s.ScanTokens()
+
+	if len(tokens) == 0 || tokens[len(tokens)-1].TokenType != token.Eof {
+		panic("token list does not end with Eof")
+	}
+
+	line := 1
+	for _, tk := range tokens {
+		if tk.Line < line || tk.Column < 1 {
+			panic(fmt.Sprintf("bad position %d:%d for %q after line %d", tk.Line, tk.Column, tk.Lexeme, line))
+		}
+		line = tk.Line
+	}
+
+	if errors > 0 {
+		return 0
+	}
+	return 1
+}
+
+// CheckParser 没有报告错误时必须得到完整的语法树
+func CheckParser(data []byte) int {
+	statements, ok := parse(data)
+	if !ok {
+		return 0
+	}
+
+	for i, stmt := range statements {
+		if stmt == nil {
+			panic(fmt.Sprintf("statement %d is nil without a syntax error", i))
+		}
+	}
+	return 1
+}
+
+// CheckInterpreter 在 StepLimit 的限制下运行程序, 运行时错误必须是 *lox.RuntimeError
+func CheckInterpreter(data []byte) int {
+	statements, ok := parse(data)
+	if !ok {
+		return 0
+	}
+
+	var out bytes.Buffer
+	// 不授予任何能力, 生成的程序不能读写文件或者访问网络
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(&out)
+	itp.SetStepLimit(StepLimit)
+
+	err := itp.Run(statements)
+	if _, ok := err.(runtime.Error); ok {
+		panic(err)
+	}
+	if itp.Depth() != 1 {
+		panic(fmt.Sprintf("call stack not unwound: depth %d", itp.Depth()))
+	}
+	if itp.Env() != itp.GetGlobalEnv() {
+		panic("environment not restored")
+	}
+
+	if err != nil {
+		if _, ok := err.(*lox.RuntimeError); !ok {
+			panic(fmt.Sprintf("unexpected error type %T: %v", err, err))
+		}
+		return 0
+	}
+	return 1
+}
+
+func parse(data []byte) ([]expr.Stmt, bool) {
+	scanErrors, parseErrors := 0, 0
+	s := scanner.NewScanner(string(data))
+	s.SetErrFunc(func(line, column int, msg string) {
+		scanErrors++
+	})
+	p := parser.NewParser(s.ScanTokens(), func(tk *token.Token, msg string) {
+		if tk == nil {
+			panic("syntax error reported without a token")
+		}
+		parseErrors++
+	})
+
+	statements := p.Parse()
+	if p.HadError() != (parseErrors > 0) {
+		panic("HadError out of sync with reported errors")
+	}
+	return statements, scanErrors+parseErrors == 0
+}
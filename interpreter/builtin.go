@@ -1,7 +1,12 @@
 package interpreter
 
 import (
+	"math"
+	"math/big"
+	"strings"
 	"time"
+
+	"github.com/zhiruchen/lox-go/lox"
 )
 
 type CLock struct{}
@@ -10,6 +15,80 @@ func (l *CLock) Arity() int {
 	return 0
 }
 
-func (l *CLock) Call(itp Interpreter, args []interface{}) interface{} {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+func (l *CLock) Call(itp *Interpreter, args []interface{}) interface{} {
+	return float64(time.Now().UnixNano()) / float64(time.Millisecond)
+}
+
+func (l *CLock) String() string {
+	return "<native fn>"
+}
+
+// NativeFunction 用 Go 实现的函数, 出错时 panic 一个 Tk 为 nil 的 *lox.RuntimeError
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(itp *Interpreter, args []interface{}) interface{}
+}
+
+func NewNativeFunction(name string, arity int, fn func(itp *Interpreter, args []interface{}) interface{}) *NativeFunction {
+	return &NativeFunction{name: name, arity: arity, fn: fn}
+}
+
+func (f *NativeFunction) Arity() int {
+	return f.arity
+}
+
+func (f *NativeFunction) Call(itp *Interpreter, args []interface{}) interface{} {
+	return f.fn(itp, args)
+}
+
+// Name 函数的名字
+func (f *NativeFunction) Name() string {
+	return f.name
+}
+
+func (f *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// bigIntOf BigInt(x), x 为整数, 整数值的小数或者字符串
+func bigIntOf(itp *Interpreter, args []interface{}) interface{} {
+	switch v := args[0].(type) {
+	case int64, *big.Int:
+		return new(big.Int).Set(toBig(v))
+	case *big.Rat:
+		if v.IsInt() {
+			return new(big.Int).Set(v.Num())
+		}
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			n, _ := new(big.Float).SetFloat64(v).Int(nil)
+			return n
+		}
+	case string:
+		if n, ok := new(big.Int).SetString(strings.TrimSpace(v), 0); ok {
+			return n
+		}
+		panic(lox.NewRuntimeError(nil, "Invalid integer string '"+v+"'."))
+	}
+	panic(lox.NewRuntimeError(nil, "BigInt() argument must be an integer or a string."))
+}
+
+// decimalOf Decimal(x), 浮点数按最短的十进制形式转换, Decimal(0.1) 就是 0.1
+func decimalOf(itp *Interpreter, args []interface{}) interface{} {
+	switch v := args[0].(type) {
+	case int64, *big.Int, *big.Rat:
+		return new(big.Rat).Set(toRat(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			panic(lox.NewRuntimeError(nil, "Cannot convert "+formatFloat(v)+" to decimal."))
+		}
+		return toRat(v)
+	case string:
+		if r, ok := new(big.Rat).SetString(strings.Replace(strings.TrimSpace(v), "_", "", -1)); ok {
+			return r
+		}
+		panic(lox.NewRuntimeError(nil, "Invalid decimal string '"+v+"'."))
+	}
+	panic(lox.NewRuntimeError(nil, "Decimal() argument must be a number or a string."))
 }
@@ -1,6 +1,7 @@
 package interpreter
 
 type Callable interface {
+	// Arity 参数个数, 小于 0 表示参数个数可变, 由函数自己检查
 	Arity() int
 	Call(itp *Interpreter, args []interface{}) interface{}
 }
@@ -0,0 +1,237 @@
+package interpreter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Capability 宿主授予脚本的一组内置功能, 每个能力对应一个全局模块
+type Capability string
+
+const (
+	CapFS       Capability = "fs"
+	CapOS       Capability = "os"
+	CapTime     Capability = "time"
+	CapNetLocal Capability = "net-local" // 只能访问本机地址, 模块名为 net
+	CapEnv      Capability = "env"
+	CapProcess  Capability = "process" // os.exec 可以运行任何程序, 不受 Paths 限制; 没有自己的模块
+)
+
+// Capabilities 所有的能力
+var Capabilities = []Capability{CapFS, CapOS, CapTime, CapNetLocal, CapEnv, CapProcess}
+
+// Config 解释器可以使用的能力, 零值不授予任何能力
+type Config struct {
+	Capabilities []Capability
+
+	// Paths fs 模块可以访问的文件和目录, 目录包括其下所有的文件
+	Paths []string
+
+	// Args os.args 的值, 命令行中脚本路径之后的参数
+	Args []string
+}
+
+// FullAccess 授予所有能力, fs 可以访问所有路径; 命令行默认使用, 嵌入时需要显式传给 NewInterpreterWithConfig
+func FullAccess() *Config {
+	return &Config{Capabilities: Capabilities, Paths: []string{string(filepath.Separator)}}
+}
+
+// NewConfig 只授予 names 中的能力, fs 只能访问 paths; names 中有未知的能力时返回错误
+func NewConfig(names, paths []string) (*Config, error) {
+	cfg := &Config{Paths: paths}
+	for _, name := range names {
+		c := Capability(strings.TrimSpace(name))
+		known := false
+		for _, k := range Capabilities {
+			known = known || k == c
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown capability %q", c)
+		}
+		cfg.Capabilities = append(cfg.Capabilities, c)
+	}
+	return cfg, nil
+}
+
+// WithArgs 与 cfg 相同但是 Args 为 args 的拷贝
+func (cfg *Config) WithArgs(args []string) *Config {
+	c := *cfg
+	c.Args = args
+	return &c
+}
+
+// Granted 是否授予了 c
+func (cfg *Config) Granted(c Capability) bool {
+	for _, granted := range cfg.Capabilities {
+		if granted == c {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleName 能力对应的全局变量名
+func moduleName(c Capability) string {
+	if c == CapNetLocal {
+		return "net"
+	}
+	return string(c)
+}
+
+// installModules 授予的能力定义为模块, 其他的定义为 denied, 使用时报告缺少的能力
+func installModules(globals *Env, cfg *Config) {
+	cfg = cfg.resolved()
+	builders := map[Capability]func(cfg *Config) *Module{
+		CapFS:       fsModule,
+		CapOS:       osModule,
+		CapTime:     timeModule,
+		CapNetLocal: netModule,
+		CapEnv:      envModule,
+	}
+
+	for _, c := range Capabilities {
+		if builders[c] == nil {
+			continue
+		}
+		if cfg.Granted(c) {
+			globals.Define(moduleName(c), builders[c](cfg))
+		} else {
+			globals.Define(moduleName(c), &denied{capability: c})
+		}
+	}
+
+	if cfg.Granted(CapTime) {
+		globals.Define("clock", &CLock{})
+	} else {
+		globals.Define("clock", &denied{capability: CapTime})
+	}
+}
+
+// denied 没有授予的能力, 调用或者访问属性时产生运行时错误
+type denied struct {
+	capability Capability
+}
+
+func (d *denied) err(tk *token.Token) *lox.RuntimeError {
+	return lox.NewRuntimeError(tk, "Capability '"+string(d.capability)+"' is not granted.")
+}
+
+func (d *denied) Arity() int {
+	return -1
+}
+
+func (d *denied) Call(itp *Interpreter, args []interface{}) interface{} {
+	panic(d.err(nil))
+}
+
+func (d *denied) Get(name *token.Token) interface{} {
+	panic(d.err(name))
+}
+
+func (d *denied) String() string {
+	return "<denied " + string(d.capability) + ">"
+}
+
+func stringArg(fn string, v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		panic(lox.NewRuntimeError(nil, fn+"() argument must be a string."))
+	}
+	return s
+}
+
+func timeModule(cfg *Config) *Module {
+	return NewModule("time", map[string]interface{}{
+		// now 从 1970 年开始的秒数
+		"now": NewNativeFunction("now", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			return float64(time.Now().UnixNano()) / float64(time.Second)
+		}),
+		"sleep": NewNativeFunction("sleep", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			if !isNumber(args[0]) {
+				panic(lox.NewRuntimeError(nil, "sleep() argument must be a number."))
+			}
+			time.Sleep(time.Duration(toFloat(args[0]) * float64(time.Second)))
+			return nil
+		}),
+	})
+}
+
+func envModule(cfg *Config) *Module {
+	return NewModule("env", map[string]interface{}{
+		"get": NewNativeFunction("get", 1, getenv("get")),
+		"set": NewNativeFunction("set", 2, setenv("set")),
+	})
+}
+
+// getenv 没有设置的环境变量返回 nil, name 为错误消息中的函数名; 环境变量属于解释器, 见 process
+func getenv(name string) func(itp *Interpreter, args []interface{}) interface{} {
+	return func(itp *Interpreter, args []interface{}) interface{} {
+		if v, ok := itp.proc.getenv(stringArg(name, args[0])); ok {
+			return v
+		}
+		return nil
+	}
+}
+
+func setenv(name string) func(itp *Interpreter, args []interface{}) interface{} {
+	return func(itp *Interpreter, args []interface{}) interface{} {
+		itp.proc.setenv(stringArg(name, args[0]), stringArg(name, args[1]))
+		return nil
+	}
+}
+
+// netModule get(url) 通过 HTTP GET 读取本机地址, 返回响应的内容
+func netModule(cfg *Config) *Module {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		// 重定向到其他主机也要检查
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return checkLocal(req.URL)
+		},
+	}
+
+	return NewModule("net", map[string]interface{}{
+		"get": NewNativeFunction("get", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			u, err := url.Parse(stringArg("get", args[0]))
+			if err != nil {
+				panic(lox.NewRuntimeError(nil, err.Error()))
+			}
+			if err := checkLocal(u); err != nil {
+				panic(lox.NewRuntimeError(nil, err.Error()))
+			}
+
+			resp, err := client.Get(u.String())
+			if err != nil {
+				panic(lox.NewRuntimeError(nil, err.Error()))
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				panic(lox.NewRuntimeError(nil, err.Error()))
+			}
+			return string(body)
+		}),
+	})
+}
+
+// checkLocal u 的主机必须是 localhost 或者回环地址
+func checkLocal(u *url.URL) error {
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return lox.NewRuntimeError(nil, "Capability 'net-local' does not allow access to '"+host+"'.")
+}
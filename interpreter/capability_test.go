@@ -0,0 +1,204 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+)
+
+// runWith 用 cfg 运行 source, 返回输出和错误
+func runWith(t *testing.T, cfg *interpreter.Config, source string) (string, error) {
+	t.Helper()
+
+	var out, stderr bytes.Buffer
+	reporter := lox.NewReporter(&stderr)
+	statements := parse(reporter, source)
+	if reporter.HadError {
+		t.Fatalf("syntax error: %s", stderr.String())
+	}
+
+	itp := interpreter.NewInterpreterWithConfig(cfg)
+	itp.SetOutput(&out)
+	err := itp.Run(statements)
+	return out.String(), err
+}
+
+// sandbox 创建 dir/allowed/data.txt 和 dir/secret.txt, 返回 dir
+func sandbox(t *testing.T) string {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "allowed"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "allowed", "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// chdir 测试结束时恢复工作目录
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestDefaultInterpreter NewInterpreter 不授予任何能力, 只有纯计算的内置模块可用
+func TestDefaultInterpreter(t *testing.T) {
+	reporter := lox.NewReporter(&bytes.Buffer{})
+
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"print math.abs(-1) + path.base(\"a/b\").len();", ""},
+		{"clock();", "Capability 'time' is not granted."},
+		{"fs.readFile(\"data.txt\");", "Capability 'fs' is not granted."},
+		{"os.exec(\"ls\", []);", "Capability 'os' is not granted."},
+		{"env.get(\"HOME\");", "Capability 'env' is not granted."},
+	}
+	for _, tt := range tests {
+		itp := interpreter.NewInterpreter()
+		itp.SetOutput(&bytes.Buffer{})
+		err := itp.Run(parse(reporter, tt.source))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.source, err, tt.err)
+		}
+	}
+}
+
+func TestAllowedPaths(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, filepath.Join(dir, "allowed"))
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), "link.txt"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapFS}, Paths: []string{"."}}
+
+	tests := []struct {
+		source, out, err string
+	}{
+		{`print fs.readFile("data.txt");`, "data\n", ""},
+		{`print fs.readFile("../secret.txt");`, "", "Capability 'fs' does not allow access to '../secret.txt'."},
+		{`print fs.readFile("link.txt");`, "", "Capability 'fs' does not allow access to 'link.txt'."},
+		{`print os.cwd();`, "", "Capability 'os' is not granted."},
+	}
+	for _, tt := range tests {
+		out, err := runWith(t, cfg, tt.source)
+		if out != tt.out {
+			t.Errorf("%s: got output %q, want %q", tt.source, out, tt.out)
+		}
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.source, err, tt.err)
+		}
+	}
+}
+
+// TestDanglingSymlink 指向允许路径之外还不存在的文件的符号链接也不能用来写入
+func TestDanglingSymlink(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, dir)
+	if err := os.Symlink(filepath.Join(dir, "outside", "pwned.txt"), filepath.Join("allowed", "evil")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("outside", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("evil", filepath.Join("allowed", "chain")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("loop", filepath.Join("allowed", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapFS}, Paths: []string{"allowed"}}
+
+	for _, path := range []string{"allowed/evil", "allowed/chain", "allowed/loop"} {
+		source := `fs.writeFile("` + path + `", "x");`
+		_, err := runWith(t, cfg, source)
+		if want := "Capability 'fs' does not allow access to '" + path + "'."; err == nil || err.Error() != want {
+			t.Errorf("%s: got error %v, want %q", source, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside", "pwned.txt")); !os.IsNotExist(err) {
+		t.Errorf("file created outside the allowed path: %v", err)
+	}
+}
+
+// TestExecNeedsProcess os 能力不包括 exec, exec 不受 Paths 限制所以单独授予
+func TestExecNeedsProcess(t *testing.T) {
+	cfg := &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapOS, interpreter.CapFS}, Paths: []string{"."}}
+	_, err := runWith(t, cfg, `os.exec("cat", ["/etc/passwd"]);`)
+	if want := "Capability 'process' is not granted."; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	cfg.Capabilities = append(cfg.Capabilities, interpreter.CapProcess)
+	out, err := runWith(t, cfg, `print os.exec("sh", ["-c", "echo ok"]).stdout;`)
+	if err != nil || out != "ok\n\n" {
+		t.Errorf("got %q, %v, want ok", out, err)
+	}
+	if _, err := runWith(t, &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapProcess}}, `os.exec("true");`); err == nil || err.Error() != "Capability 'os' is not granted." {
+		t.Errorf("exec without os: got error %v", err)
+	}
+}
+
+// TestAllowedPathsAfterChdir 相对的允许路径在创建解释器时解析, os.chdir 不能离开允许的目录,
+// 也不会改变宿主进程的工作目录
+func TestAllowedPathsAfterChdir(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, filepath.Join(dir, "allowed"))
+	if err := os.Mkdir("sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &interpreter.Config{
+		Capabilities: []interpreter.Capability{interpreter.CapFS, interpreter.CapOS},
+		Paths:        []string{"."},
+	}
+
+	out, err := runWith(t, cfg, `
+print os.chdir("sub");
+print path.base(os.cwd());
+print fs.readFile("../data.txt");
+print os.chdir("missing").message;
+print os.chdir("../data.txt").message;
+os.chdir("../..");
+`)
+	if want := "nil\nsub\ndata\nCannot change to 'missing': no such file or directory.\nCannot change to '../data.txt': not a directory.\n"; out != want {
+		t.Errorf("got output %q, want %q", out, want)
+	}
+	if want := "Capability 'fs' does not allow access to '../..'."; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	_, err = runWith(t, cfg, `print fs.readFile("../secret.txt");`)
+	if want := "Capability 'fs' does not allow access to '../secret.txt'."; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	if wd, _ := os.Getwd(); wd != filepath.Join(dir, "allowed") {
+		t.Errorf("host working directory changed to %s", wd)
+	}
+}
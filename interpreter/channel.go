@@ -0,0 +1,239 @@
+package interpreter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Channel channel() 或者 channel(capacity) 创建的通道, 所有状态由创建它的任务组的锁 mu 保护;
+// 只能在创建它的解释器中使用
+type Channel struct {
+	mu       *sync.Mutex
+	capacity int
+	buffer   []interface{}
+	closed   bool
+	recvq    []pending
+	sendq    []pending
+}
+
+// pending 阻塞在通道上的一个操作, 是 w.ops 中的第 index 个
+type pending struct {
+	w     *waiter
+	index int
+}
+
+// chanOp 通道上的一个发送或者接收操作
+type chanOp struct {
+	ch    *Channel
+	send  bool
+	value interface{} // 要发送的值
+	tk    *token.Token
+}
+
+func channelOf(itp *Interpreter, args []interface{}) interface{} {
+	if len(args) > 1 {
+		panic(lox.NewRuntimeError(nil, fmt.Sprintf("Expected 0 to 1 arguments but got %d.", len(args))))
+	}
+
+	capacity := int64(0)
+	if len(args) == 1 {
+		n, ok := integerValue(args[0])
+		if !ok || n < 0 {
+			panic(lox.NewRuntimeError(nil, "Channel capacity must be a non-negative integer."))
+		}
+		capacity = n
+	}
+	return &Channel{mu: itp.group.mu, capacity: int(capacity)}
+}
+
+// dequeue 取出第一个还在等待的操作, 被其他 case 唤醒过的跳过
+func dequeue(q *[]pending) *pending {
+	for len(*q) > 0 {
+		p := (*q)[0]
+		*q = (*q)[1:]
+		if !p.w.fired {
+			return &p
+		}
+	}
+	return nil
+}
+
+func (ch *Channel) tryRecv() (interface{}, bool) {
+	if len(ch.buffer) > 0 {
+		value := ch.buffer[0]
+		ch.buffer = ch.buffer[1:]
+		// 缓冲区有了空位, 让一个阻塞的发送者把值放进来
+		if s := dequeue(&ch.sendq); s != nil {
+			ch.buffer = append(ch.buffer, s.w.ops[s.index].value)
+			s.w.complete(s.index, nil)
+		}
+		return value, true
+	}
+	if s := dequeue(&ch.sendq); s != nil {
+		s.w.complete(s.index, nil)
+		return s.w.ops[s.index].value, true
+	}
+	if ch.closed {
+		return nil, true
+	}
+	return nil, false
+}
+
+func (ch *Channel) trySend(op chanOp) bool {
+	if ch.closed {
+		panic(lox.NewRuntimeError(op.tk, "Send on closed channel."))
+	}
+	if r := dequeue(&ch.recvq); r != nil {
+		r.w.complete(r.index, op.value)
+		return true
+	}
+	if len(ch.buffer) < ch.capacity {
+		ch.buffer = append(ch.buffer, op.value)
+		return true
+	}
+	return false
+}
+
+// close 唤醒所有等待的任务: 接收者收到 nil, 发送者出错
+func (ch *Channel) close(itp *Interpreter, tk *token.Token) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.checkOwner(itp, tk)
+	if ch.closed {
+		panic(lox.NewRuntimeError(tk, "Channel is already closed."))
+	}
+	ch.closed = true
+
+	for r := dequeue(&ch.recvq); r != nil; r = dequeue(&ch.recvq) {
+		r.w.complete(r.index, nil)
+	}
+	for s := dequeue(&ch.sendq); s != nil; s = dequeue(&ch.sendq) {
+		s.w.fail(lox.NewRuntimeError(s.w.ops[s.index].tk, "Send on closed channel."))
+	}
+}
+
+// perform 完成 ops 中第一个就绪的操作, 返回它的下标和收到的值;
+// 都没有就绪时, hasDefault 为 true 则返回 -1, 否则阻塞直到某个操作被其他任务完成
+func (itp *Interpreter) perform(ops []chanOp, hasDefault bool, tk *token.Token) (int, interface{}) {
+	w, index, value := itp.tryPerform(ops, hasDefault, tk)
+	if w == nil {
+		return index, value
+	}
+
+	w.wait()
+	return w.index, w.value
+}
+
+// checkOwner 其他解释器的任务组使用不同的锁, 不能访问这个通道
+func (ch *Channel) checkOwner(itp *Interpreter, tk *token.Token) {
+	if ch.mu != itp.group.mu {
+		panic(lox.NewRuntimeError(tk, "Cannot use a channel created by another interpreter."))
+	}
+}
+
+func (itp *Interpreter) tryPerform(ops []chanOp, hasDefault bool, tk *token.Token) (*waiter, int, interface{}) {
+	g := itp.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		panic(g.err)
+	}
+	for _, op := range ops {
+		op.ch.checkOwner(itp, op.tk)
+	}
+
+	for i, op := range ops {
+		if op.send {
+			if op.ch.trySend(op) {
+				return nil, i, nil
+			}
+		} else if value, ok := op.ch.tryRecv(); ok {
+			return nil, i, value
+		}
+	}
+	if hasDefault {
+		return nil, -1, nil
+	}
+
+	w := newWaiter(g, tk, ops)
+	for i, op := range ops {
+		if op.send {
+			op.ch.sendq = append(op.ch.sendq, pending{w: w, index: i})
+		} else {
+			op.ch.recvq = append(op.ch.recvq, pending{w: w, index: i})
+		}
+	}
+	g.block(w)
+	return w, 0, nil
+}
+
+// Get send(value) 发送, 没有接收者并且缓冲区已满时阻塞; recv() 接收, 通道关闭并且没有剩余的值时返回 nil; close() 关闭
+func (ch *Channel) Get(name *token.Token) interface{} {
+	switch name.Lexeme {
+	case "send":
+		return NewNativeFunction("send", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			itp.perform([]chanOp{{ch: ch, send: true, value: args[0]}}, false, nil)
+			return nil
+		})
+	case "recv":
+		return NewNativeFunction("recv", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			_, value := itp.perform([]chanOp{{ch: ch}}, false, nil)
+			return value
+		})
+	case "close":
+		return NewNativeFunction("close", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			ch.close(itp, nil)
+			return nil
+		})
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (ch *Channel) String() string {
+	return "<channel>"
+}
+
+// VisitorSelectStmtExpr 先对所有 case 的通道和要发送的值求值, 然后执行第一个就绪的操作所在的 case
+func (itp *Interpreter) VisitorSelectStmtExpr(stmt *expr.Select) interface{} {
+	var ops []chanOp
+	var cases []*expr.SelectCase
+	var fallback *expr.SelectCase
+
+	for _, c := range stmt.Cases {
+		if c.Operation == nil {
+			fallback = c
+			continue
+		}
+
+		ch, ok := itp.evaluate(c.Channel).(*Channel)
+		if !ok {
+			panic(lox.NewRuntimeError(c.Operation, "Can only select on channels."))
+		}
+		op := chanOp{ch: ch, tk: c.Operation}
+		if c.Operation.Lexeme == "send" {
+			op.send = true
+			op.value = itp.evaluate(c.Value)
+		}
+		ops = append(ops, op)
+		cases = append(cases, c)
+	}
+
+	index, value := itp.perform(ops, fallback != nil, stmt.Keyword)
+
+	chosen := fallback
+	if index >= 0 {
+		chosen = cases[index]
+	}
+	env := NewEnvWithEnclosing(itp.env)
+	if chosen.Name != nil {
+		env.Define(chosen.Name.Lexeme, value)
+	}
+	itp.executeBlock([]expr.Stmt{chosen.Body}, env)
+	return nil
+}
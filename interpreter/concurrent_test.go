@@ -0,0 +1,203 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+)
+
+// parse 扫描和语法错误通过 reporter 报告
+func parse(reporter *lox.Reporter, source string) []expr.Stmt {
+	s := scanner.NewScanner(source)
+	s.SetErrFunc(reporter.ScanError)
+	p := parser.NewParser(s.ScanTokens(), reporter.TokenError)
+	return p.Parse()
+}
+
+const prelude = `
+var config = {"greeting": "hello", "sizes": [1, 2, 3]};
+fun greet(name) {
+  return config.greeting + " " + name;
+}
+fun sum(n) {
+  var total = 0;
+  for (var i in range(n)) total += i;
+  return total;
+}
+`
+
+// TestSharedGlobals 在 go test -race 下运行: 多个解释器同时使用 NewGlobals 返回的全局环境,
+// 每个解释器有自己的输出, Reporter 和全局声明
+func TestSharedGlobals(t *testing.T) {
+	reporter := lox.NewReporter(&bytes.Buffer{})
+	globals, err := interpreter.NewGlobals(parse(reporter, prelude), interpreter.FullAccess())
+	if err != nil || reporter.HadError {
+		t.Fatalf("prelude failed: %v", err)
+	}
+
+	const n = 16
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, n)
+	errors := make([]bytes.Buffer, n)
+	results := make([]error, n)
+	reporters := make([]*lox.Reporter, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			itp := interpreter.NewInterpreterWithGlobals(globals)
+			itp.SetOutput(&outputs[i])
+			reporters[i] = lox.NewReporter(&errors[i])
+			itp.SetReporter(reporters[i])
+
+			// 奇数编号的脚本有语法错误, 只影响它自己的 Reporter
+			source := fmt.Sprintf(`
+var id = %d;
+var seen = {};
+for (var i in range(50)) seen.set(i, sum(i));
+print greet("task ${id}");
+print seen.len() + seen.get(3);
+config.greeting = "changed";
+`, i)
+			if i%2 == 1 {
+				source += "print ;\n"
+			}
+
+			statements := parse(itp.Reporter(), source)
+			if itp.Reporter().HadError {
+				return
+			}
+			results[i] = itp.Run(statements)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if i%2 == 1 {
+			if !reporters[i].HadError || outputs[i].Len() != 0 {
+				t.Errorf("script %d: expected only a syntax error, got output %q", i, outputs[i].String())
+			}
+			if want := "[line 8] Error at ';': Expect expression.\n"; errors[i].String() != want {
+				t.Errorf("script %d: got stderr %q, want %q", i, errors[i].String(), want)
+			}
+			continue
+		}
+
+		if reporters[i].HadError || errors[i].Len() != 0 {
+			t.Errorf("script %d: unexpected errors %q", i, errors[i].String())
+		}
+		if want := fmt.Sprintf("hello task %d\n53\n", i); outputs[i].String() != want {
+			t.Errorf("script %d: got output %q, want %q", i, outputs[i].String(), want)
+		}
+		if results[i] == nil || !strings.Contains(results[i].Error(), "frozen map") {
+			t.Errorf("script %d: got %v, want an error for modifying the frozen map", i, results[i])
+		}
+	}
+}
+
+func TestFrozenGlobals(t *testing.T) {
+	reporter := lox.NewReporter(&bytes.Buffer{})
+	globals, err := interpreter.NewGlobals(parse(reporter, prelude), interpreter.FullAccess())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source, err string
+	}{
+		{`greet = nil;`, "Cannot assign to 'greet' in a frozen environment."},
+		{`config.set("greeting", 1);`, "Cannot modify a frozen map."},
+		{`var greet = 1; print greet;`, ""},
+	}
+	for _, tt := range tests {
+		itp := interpreter.NewInterpreterWithGlobals(globals)
+		itp.SetOutput(&bytes.Buffer{})
+		err := itp.Run(parse(reporter, tt.source))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.source, err, tt.err)
+		}
+	}
+}
+
+// TestAbandonedTasks 脚本出错后, 还在运行的 spawn 出的任务结束, 不会泄漏 goroutine
+func TestAbandonedTasks(t *testing.T) {
+	reporter := lox.NewReporter(&bytes.Buffer{})
+	before := runtime.NumGoroutine()
+
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(&bytes.Buffer{})
+	err := itp.Run(parse(reporter, `
+fun spin() {
+  while (true) {}
+}
+spawn spin();
+spawn spin();
+print missing;
+`))
+	if want := "Undefined variable 'missing'."; err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %q", err, want)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 之后的脚本在新的任务组中运行
+	var out bytes.Buffer
+	itp.SetOutput(&out)
+	if err := itp.Run(parse(reporter, "var ch = channel();\nspawn ch.send(1);\nprint ch.recv();")); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "1\n" {
+		t.Errorf("got output %q, want 1", out.String())
+	}
+}
+
+// TestChannelOwner 每个解释器的通道由自己的锁保护, 不能在其他解释器中使用
+func TestChannelOwner(t *testing.T) {
+	reporter := lox.NewReporter(&bytes.Buffer{})
+
+	a := interpreter.NewInterpreter()
+	var out bytes.Buffer
+	a.SetOutput(&out)
+	if err := a.Run(parse(reporter, "var ch = channel(1);")); err != nil {
+		t.Fatal(err)
+	}
+	ch := a.GetGlobalEnv().Values()["ch"]
+
+	b := interpreter.NewInterpreter()
+	b.GetGlobalEnv().Define("ch", ch)
+	for _, source := range []string{"ch.send(1);", "ch.recv();", "ch.close();", "select { case var v = ch.recv() => print v; case _ => print 0; }"} {
+		err := b.Run(parse(reporter, source))
+		if want := "Cannot use a channel created by another interpreter."; err == nil || err.Error() != want {
+			t.Errorf("%s: got error %v, want %q", source, err, want)
+		}
+	}
+
+	// 出错之后创建的任务组沿用同一个锁, 之前的通道仍然可以使用
+	if err := a.Run(parse(reporter, "print missing;")); err == nil {
+		t.Fatal("got no error for an undefined variable")
+	}
+	if err := a.Run(parse(reporter, "ch.send(2);\nprint ch.recv();")); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "2\n" {
+		t.Errorf("got output %q, want 2", out.String())
+	}
+}
@@ -1,12 +1,19 @@
 package interpreter
 
 import (
+	"sync"
+
+	"github.com/zhiruchen/lox-go/lox"
 	"github.com/zhiruchen/lox-go/token"
 )
 
+// Env 变量的作用域, spawn 出的任务可能同时访问同一个环境, 所以 values 由 mu 保护;
+// 冻结之后 values 不再改变, 读取时不需要加锁
 type Env struct {
 	Enclosing *Env
+	mu        sync.RWMutex
 	values    map[string]interface{}
+	frozen    bool
 }
 
 func NewEnv() *Env {
@@ -23,13 +30,55 @@ func NewEnvWithEnclosing(enclosing *Env) *Env {
 	}
 }
 
+// Depth 环境链的长度, 全局环境为 1
+func (env *Env) Depth() int {
+	depth := 0
+	for e := env; e != nil; e = e.Enclosing {
+		depth++
+	}
+	return depth
+}
+
+// Values 当前作用域 (不含 Enclosing) 中变量的拷贝
+func (env *Env) Values() map[string]interface{} {
+	if !env.frozen {
+		env.mu.RLock()
+		defer env.mu.RUnlock()
+	}
+
+	values := make(map[string]interface{}, len(env.values))
+	for k, v := range env.values {
+		values[k] = v
+	}
+	return values
+}
+
+// Freeze 之后 Define 和 Assign 都会产生运行时错误, 其中的映射也不能再修改;
+// 必须在环境被其他 goroutine 使用之前调用
+func (env *Env) Freeze() {
+	env.frozen = true
+	for _, v := range env.values {
+		freeze(v)
+	}
+}
+
+// Frozen 是否已经冻结
+func (env *Env) Frozen() bool {
+	return env.frozen
+}
+
 func (env *Env) Define(name string, value interface{}) {
+	if env.frozen {
+		panic(lox.NewRuntimeError(nil, "Cannot define '"+name+"' in a frozen environment."))
+	}
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
 	env.values[name] = value
 }
 
 func (env *Env) Get(name *token.Token) interface{} {
-	v, ok := env.values[name.Lexeme]
-	if ok {
+	if v, ok := env.lookup(name.Lexeme); ok {
 		return v
 	}
 
@@ -37,12 +86,11 @@ func (env *Env) Get(name *token.Token) interface{} {
 		return env.Enclosing.Get(name)
 	}
 
-	panic("Undefined variable '" + name.Lexeme + "'.")
+	panic(lox.NewRuntimeError(name, "Undefined variable '"+name.Lexeme+"'."))
 }
 
 func (env *Env) Assign(name *token.Token, value interface{}) {
-	if _, ok := env.values[name.Lexeme]; ok {
-		env.values[name.Lexeme] = value
+	if env.assign(name, value) {
 		return
 	}
 
@@ -51,5 +99,36 @@ func (env *Env) Assign(name *token.Token, value interface{}) {
 		return
 	}
 
-	panic("Undefined variable" + name.Lexeme + ".")
+	panic(lox.NewRuntimeError(name, "Undefined variable '"+name.Lexeme+"'."))
+}
+
+func (env *Env) lookup(name string) (interface{}, bool) {
+	if env.frozen {
+		v, ok := env.values[name]
+		return v, ok
+	}
+
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	v, ok := env.values[name]
+	return v, ok
+}
+
+// assign name 在当前作用域中时赋值并返回 true
+func (env *Env) assign(name *token.Token, value interface{}) bool {
+	if env.frozen {
+		if _, ok := env.values[name.Lexeme]; ok {
+			panic(lox.NewRuntimeError(name, "Cannot assign to '"+name.Lexeme+"' in a frozen environment."))
+		}
+		return false
+	}
+
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	if _, ok := env.values[name.Lexeme]; ok {
+		env.values[name.Lexeme] = value
+		return true
+	}
+	return false
 }
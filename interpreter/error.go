@@ -0,0 +1,32 @@
+package interpreter
+
+import (
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Error 可以由脚本处理的失败, 例如 fs 模块中的 I/O 错误; 作为函数的返回值而不是产生运行时错误.
+// 属性 message 为错误信息, 用 isError(v) 判断
+type Error struct {
+	Message string
+}
+
+func NewError(message string) *Error {
+	return &Error{Message: message}
+}
+
+func (e *Error) Get(name *token.Token) interface{} {
+	if name.Lexeme == "message" {
+		return e.Message
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (e *Error) String() string {
+	return "<error " + e.Message + ">"
+}
+
+func isError(itp *Interpreter, args []interface{}) interface{} {
+	_, ok := args[0].(*Error)
+	return ok
+}
@@ -0,0 +1,121 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/zhiruchen/lox-go/expr"
+)
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Name string
+	Line int  // 正在执行的语句所在行
+	Env  *Env // 正在执行的语句所在的环境
+}
+
+// Hook 在执行每条语句之前被调用, 用于调试器等工具
+type Hook interface {
+	BeforeExecute(itp *Interpreter, stmt expr.Stmt)
+}
+
+// CallHook 可选接口, Hook 实现它时在 Lox 函数调用前后被调用
+type CallHook interface {
+	EnterFunction(itp *Interpreter, fn *Function)
+	ExitFunction(itp *Interpreter, fn *Function)
+}
+
+// BranchHook 可选接口, 在 if/while/?: 的条件, and/or/?? 的左操作数, match 的每个 case
+// 和 for-in 的每次取值后被调用
+//
+// node 为 *expr.IF, *expr.While, *expr.ForIn, *expr.Conditional, *expr.Logical 或者 *expr.Case, taken 表示:
+// if 进入 then 分支, while 和 for-in 进入循环体, ?: 选择 then 分支, and/or/?? 短路, case 匹配
+type BranchHook interface {
+	Branch(itp *Interpreter, node interface{}, taken bool)
+}
+
+// MultiHook 按顺序调用多个钩子
+func MultiHook(hooks ...Hook) Hook {
+	return multiHook(hooks)
+}
+
+type multiHook []Hook
+
+func (hooks multiHook) BeforeExecute(itp *Interpreter, stmt expr.Stmt) {
+	for _, h := range hooks {
+		h.BeforeExecute(itp, stmt)
+	}
+}
+
+func (hooks multiHook) EnterFunction(itp *Interpreter, fn *Function) {
+	for _, h := range hooks {
+		if ch, ok := h.(CallHook); ok {
+			ch.EnterFunction(itp, fn)
+		}
+	}
+}
+
+func (hooks multiHook) ExitFunction(itp *Interpreter, fn *Function) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if ch, ok := hooks[i].(CallHook); ok {
+			ch.ExitFunction(itp, fn)
+		}
+	}
+}
+
+func (hooks multiHook) Branch(itp *Interpreter, node interface{}, taken bool) {
+	for _, h := range hooks {
+		if bh, ok := h.(BranchHook); ok {
+			bh.Branch(itp, node, taken)
+		}
+	}
+}
+
+// SetHook 设置执行钩子, nil 表示不使用
+func (itp *Interpreter) SetHook(hook Hook) {
+	itp.hook = hook
+}
+
+// Frames 当前调用栈的拷贝, 最内层的帧在最后
+func (itp *Interpreter) Frames() []Frame {
+	frames := make([]Frame, len(itp.frames))
+	for i, f := range itp.frames {
+		frames[i] = *f
+	}
+	return frames
+}
+
+func (itp *Interpreter) branch(node interface{}, taken bool) {
+	if hook, ok := itp.hook.(BranchHook); ok {
+		hook.Branch(itp, node, taken)
+	}
+}
+
+func (itp *Interpreter) pushFrame(name string, env *Env) {
+	itp.frames = append(itp.frames, &Frame{Name: name, Env: env})
+}
+
+func (itp *Interpreter) popFrame() {
+	itp.frames = itp.frames[:len(itp.frames)-1]
+}
+
+// toError 把解释器内部的 panic 值转换为 error
+func toError(r interface{}) error {
+	switch e := r.(type) {
+	case error:
+		return e
+	case *returnValue:
+		return fmt.Errorf("Cannot return from top-level code.")
+	default:
+		return fmt.Errorf("%v", e)
+	}
+}
+
+// Env 正在执行的语句所在的环境
+func (itp *Interpreter) Env() *Env {
+	return itp.env
+}
+
+// Depth 调用栈的深度, 顶层脚本为 1
+func (itp *Interpreter) Depth() int {
+	return len(itp.frames)
+}
@@ -0,0 +1,322 @@
+package interpreter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// fsModule 所有路径都要经过 cfg.checkPath, 使用它返回的解析过的路径, 不允许访问时产生运行时错误;
+// I/O 失败时返回 *Error, 消息中包含脚本给出的路径, 没有结果的函数成功时返回 nil
+func fsModule(cfg *Config) *Module {
+	return NewModule("fs", map[string]interface{}{
+		"readFile": NewNativeFunction("readFile", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("readFile", args[0])
+			f, err := cfg.openChecked(itp, path, os.O_RDONLY)
+			if err != nil {
+				return fsError("read", path, err)
+			}
+			defer f.Close()
+			data, err := ioutil.ReadAll(f)
+			if err != nil {
+				return fsError("read", path, err)
+			}
+			return string(data)
+		}),
+		"writeFile": NewNativeFunction("writeFile", 2, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("writeFile", args[0])
+			data := stringArg("writeFile", args[1])
+			f, err := cfg.openChecked(itp, path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+			if err == nil {
+				_, err = f.WriteString(data)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err != nil {
+				return fsError("write", path, err)
+			}
+			return nil
+		}),
+		"appendFile": NewNativeFunction("appendFile", 2, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("appendFile", args[0])
+			data := stringArg("appendFile", args[1])
+			f, err := cfg.openChecked(itp, path, os.O_WRONLY|os.O_APPEND|os.O_CREATE)
+			if err == nil {
+				_, err = f.WriteString(data)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err != nil {
+				return fsError("append to", path, err)
+			}
+			return nil
+		}),
+		"exists": NewNativeFunction("exists", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			_, err := os.Lstat(cfg.checkPath(itp, stringArg("exists", args[0])))
+			return err == nil
+		}),
+		// listDir 目录中的文件名, 按名字排序
+		"listDir": NewNativeFunction("listDir", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("listDir", args[0])
+			infos, err := ioutil.ReadDir(cfg.checkPath(itp, path))
+			if err != nil {
+				return fsError("list", path, err)
+			}
+			names := make([]string, len(infos))
+			for i, info := range infos {
+				names[i] = info.Name()
+			}
+			sort.Strings(names)
+			return stringList(names)
+		}),
+		"mkdirAll": NewNativeFunction("mkdirAll", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("mkdirAll", args[0])
+			// 创建解析过的路径, 之后再检查一次, 发现检查之后被换成的符号链接
+			if err := os.MkdirAll(cfg.checkPath(itp, path), 0755); err != nil {
+				return fsError("create", path, err)
+			}
+			cfg.checkPath(itp, path)
+			return nil
+		}),
+		// remove 删除文件或者空目录
+		"remove": NewNativeFunction("remove", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("remove", args[0])
+			if err := os.Remove(cfg.checkEntry(itp, path)); err != nil {
+				return fsError("remove", path, err)
+			}
+			return nil
+		}),
+		"stat": NewNativeFunction("stat", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("stat", args[0])
+			info, err := os.Lstat(cfg.checkPath(itp, path))
+			if err != nil {
+				return fsError("stat", path, err)
+			}
+			return &FileInfo{info}
+		}),
+		// lines 逐行读取文件, 返回的值可以用 for-in 遍历
+		"lines": NewNativeFunction("lines", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("lines", args[0])
+			f, err := cfg.openChecked(itp, path, os.O_RDONLY)
+			if err != nil {
+				return fsError("read", path, err)
+			}
+			return newLineReader(path, f)
+		}),
+	})
+}
+
+// fsError 去掉 *os.PathError 中的绝对路径, 使用脚本给出的路径
+func fsError(op, path string, err error) *Error {
+	if e, ok := err.(*os.PathError); ok {
+		err = e.Err
+	}
+	return NewError("Cannot " + op + " '" + path + "': " + err.Error() + ".")
+}
+
+// resolved cfg 的拷贝, 其中的 Paths 都是解析过符号链接的绝对路径;
+// 在创建解释器时调用一次, 之后脚本改变工作目录不会改变允许访问的位置
+func (cfg *Config) resolved() *Config {
+	c := *cfg
+	c.Paths = nil
+	for _, allowed := range cfg.Paths {
+		root, err := filepath.Abs(allowed)
+		if err != nil {
+			continue
+		}
+		if resolved, ok := resolveLinks(root, 0); ok {
+			c.Paths = append(c.Paths, resolved)
+		}
+	}
+	return &c
+}
+
+// checkPath 返回 path (相对于 itp 的工作目录) 解析过所有符号链接的绝对路径, 不在 cfg.Paths 中
+// 或者无法完全解析时产生运行时错误. 调用者使用返回的路径, 检查之后才被换成符号链接的部分不会被跟随.
+// cfg 必须是 resolved 返回的
+func (cfg *Config) checkPath(itp *Interpreter, path string) string {
+	resolved, ok := resolveLinks(itp.proc.abs(path), 0)
+	return cfg.allow(path, resolved, ok)
+}
+
+// checkEntry 与 checkPath 相同, 但是不解析最后一个部分, 检查的是目录项自己所在的位置;
+// 用于 remove, 删除符号链接时删除的是链接本身而不是它指向的文件
+func (cfg *Config) checkEntry(itp *Interpreter, path string) string {
+	abs := itp.proc.abs(path)
+	dir, ok := resolveLinks(filepath.Dir(abs), 0)
+	return cfg.allow(path, filepath.Join(dir, filepath.Base(abs)), ok)
+}
+
+// allow 返回 resolved, 它不在 cfg.Paths 中或者 ok 为 false 时产生运行时错误;
+// path 是脚本给出的路径, 只用于错误消息
+func (cfg *Config) allow(path, resolved string, ok bool) string {
+	if ok {
+		for _, root := range cfg.Paths {
+			if resolved == root || strings.HasPrefix(resolved, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+				return resolved
+			}
+		}
+	}
+	panic(lox.NewRuntimeError(nil, "Capability 'fs' does not allow access to '"+path+"'."))
+}
+
+// maxLinks 解析一个路径时最多跟随的符号链接数, 超过时认为有循环
+const maxLinks = 40
+
+// resolveLinks 解析 path 中的符号链接; 不存在的部分原样保留, 但是指向不存在位置的符号链接
+// 会按照它的目标继续解析. 遇到其它错误或者链接太多时返回 false
+func resolveLinks(path string, links int) (string, bool) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved, true
+	}
+	dir := filepath.Dir(path)
+	if dir == path {
+		return path, true
+	}
+
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink != 0:
+		if links >= maxLinks {
+			return "", false
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", false
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		return resolveLinks(target, links+1)
+	case err != nil && !os.IsNotExist(err) && !errors.Is(err, syscall.ENOTDIR):
+		return "", false
+	}
+
+	parent, ok := resolveLinks(dir, links)
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(parent, filepath.Base(path)), true
+}
+
+// openChecked 检查 path 之后打开解析过的路径; 最后一个部分用 oNoFollow 打开,
+// 检查之后才换成的符号链接会让打开失败而不是跟随它. stat 和 exists 同样用 Lstat 而不是 Stat
+func (cfg *Config) openChecked(itp *Interpreter, path string, flag int) (*os.File, error) {
+	return os.OpenFile(cfg.checkPath(itp, path), flag|oNoFollow, 0644)
+}
+
+// FileInfo fs.stat() 的结果, 属性有 name, size, isDir, modified (从 1970 年开始的秒数)
+type FileInfo struct {
+	info os.FileInfo
+}
+
+func (fi *FileInfo) Get(name *token.Token) interface{} {
+	switch name.Lexeme {
+	case "name":
+		return fi.info.Name()
+	case "size":
+		return fi.info.Size()
+	case "isDir":
+		return fi.info.IsDir()
+	case "modified":
+		return float64(fi.info.ModTime().UnixNano()) / 1e9
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (fi *FileInfo) String() string {
+	return "<stat " + fi.info.Name() + ">"
+}
+
+// LineReader fs.lines() 的结果, 与生成器一样有 next(), close() 和 done; 每行不含换行符, 读完后关闭文件
+type LineReader struct {
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	reader *bufio.Reader
+	done   bool
+}
+
+func newLineReader(path string, f *os.File) *LineReader {
+	lr := &LineReader{path: path, file: f, reader: bufio.NewReader(f)}
+	runtime.SetFinalizer(lr, func(lr *LineReader) {
+		lr.close()
+	})
+	return lr
+}
+
+// Next 实现 Iterator, 读到文件结尾时返回 false; 读取失败时关闭文件, 最后一个值为 *Error
+func (lr *LineReader) Next(itp *Interpreter) (interface{}, bool) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if lr.done {
+		return nil, false
+	}
+	line, err := lr.reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		lr.closeLocked()
+		return fsError("read", lr.path, err), true
+	}
+	if err == io.EOF && line == "" {
+		lr.closeLocked()
+		return nil, false
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), true
+}
+
+func (lr *LineReader) Iterator() Iterator {
+	return lr
+}
+
+func (lr *LineReader) close() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	lr.closeLocked()
+}
+
+func (lr *LineReader) closeLocked() {
+	if !lr.done {
+		lr.done = true
+		lr.file.Close()
+	}
+}
+
+func (lr *LineReader) Get(name *token.Token) interface{} {
+	switch name.Lexeme {
+	case "next":
+		return NewNativeFunction("next", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			line, _ := lr.Next(itp)
+			return line
+		})
+	case "close":
+		return NewNativeFunction("close", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			lr.close()
+			return nil
+		})
+	case "done":
+		lr.mu.Lock()
+		defer lr.mu.Unlock()
+		return lr.done
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (lr *LineReader) String() string {
+	return "<lines " + lr.path + ">"
+}
@@ -0,0 +1,138 @@
+package interpreter_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+func TestFileSystem(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, dir)
+	cfg := &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapFS}, Paths: []string{"allowed"}}
+
+	out, err := runWith(t, cfg, `
+print fs.writeFile("allowed/new.txt", "a\nb\n");
+fs.appendFile("allowed/new.txt", "c");
+print fs.readFile("allowed/new.txt").split("\n");
+for (var line in fs.lines("allowed/new.txt")) print line;
+print fs.listDir("allowed");
+var info = fs.stat("allowed/new.txt");
+print "${info.name} ${info.size} ${info.isDir}";
+print fs.remove("allowed/new.txt");
+print fs.exists("allowed/new.txt");
+
+// I/O 失败返回错误值, 不允许访问的路径仍然是运行时错误
+print isError(fs.readFile("allowed/new.txt"));
+print fs.listDir("allowed/missing").message;
+print fs.mkdirAll("allowed/data.txt/sub").message;
+fs.readFile("secret.txt");
+`)
+	want := `nil
+[a, b, c]
+a
+b
+c
+[data.txt, new.txt]
+new.txt 5 false
+nil
+false
+true
+Cannot list 'allowed/missing': no such file or directory.
+Cannot create 'allowed/data.txt/sub': not a directory.
+`
+	if out != want {
+		t.Errorf("got output\n%s\nwant\n%s", out, want)
+	}
+	if want := "Capability 'fs' does not allow access to 'secret.txt'."; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+// TestWriteThroughSymlink 允许路径中指向外面的符号链接不能用来写入, 不管目标是否已经存在
+func TestWriteThroughSymlink(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, dir)
+	if err := os.Mkdir("outside", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join("outside", "existing.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"existingFile": filepath.Join(dir, "outside", "existing.txt"),
+		"missingFile":  filepath.Join(dir, "outside", "missing.txt"),
+		"existingDir":  filepath.Join(dir, "outside"),
+		"missingDir":   filepath.Join(dir, "outside", "missing"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join("allowed", name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapFS}, Paths: []string{"allowed"}}
+
+	tests := []struct {
+		source, path string
+	}{
+		{`fs.writeFile("allowed/existingFile", "x");`, "allowed/existingFile"},
+		{`fs.writeFile("allowed/missingFile", "x");`, "allowed/missingFile"},
+		{`fs.appendFile("allowed/existingFile", "x");`, "allowed/existingFile"},
+		{`fs.appendFile("allowed/missingFile", "x");`, "allowed/missingFile"},
+		{`fs.writeFile("allowed/existingDir/new.txt", "x");`, "allowed/existingDir/new.txt"},
+		{`fs.mkdirAll("allowed/existingDir/sub");`, "allowed/existingDir/sub"},
+		{`fs.mkdirAll("allowed/missingDir");`, "allowed/missingDir"},
+		{`fs.mkdirAll("allowed/missingDir/sub");`, "allowed/missingDir/sub"},
+	}
+	for _, tt := range tests {
+		_, err := runWith(t, cfg, tt.source)
+		if want := "Capability 'fs' does not allow access to '" + tt.path + "'."; err == nil || err.Error() != want {
+			t.Errorf("%s: got error %v, want %q", tt.source, err, want)
+		}
+	}
+
+	infos, err := ioutil.ReadDir("outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "existing.txt" {
+		t.Errorf("files created outside the allowed path: %v", infos)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join("outside", "existing.txt")); string(data) != "old" {
+		t.Errorf("file outside the allowed path changed to %q", data)
+	}
+}
+
+// TestRemoveSymlink remove 检查和删除的都是符号链接本身, 而不是它指向的文件
+func TestRemoveSymlink(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, dir)
+	if err := os.Symlink(filepath.Join(dir, "allowed", "data.txt"), "inward"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join("allowed", "outward")); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &interpreter.Config{Capabilities: []interpreter.Capability{interpreter.CapFS}, Paths: []string{"allowed"}}
+
+	_, err := runWith(t, cfg, `fs.remove("inward");`)
+	if want := "Capability 'fs' does not allow access to 'inward'."; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+	if _, err := os.Lstat("inward"); err != nil {
+		t.Errorf("link outside the allowed path removed: %v", err)
+	}
+
+	if _, err := runWith(t, cfg, `fs.remove("allowed/outward");`); err != nil {
+		t.Errorf("remove link inside the allowed path: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join("allowed", "outward")); !os.IsNotExist(err) {
+		t.Errorf("link not removed: %v", err)
+	}
+	if _, err := os.Stat("secret.txt"); err != nil {
+		t.Errorf("link target removed: %v", err)
+	}
+}
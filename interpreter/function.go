@@ -6,27 +6,60 @@ import (
 
 type Function struct {
 	declaration *expr.Function
+	closure     *Env
 }
 
-func NewFunction(declaration *expr.Function) *Function {
-	return &Function{declaration: declaration}
+func NewFunction(declaration *expr.Function, closure *Env) *Function {
+	return &Function{declaration: declaration, closure: closure}
 }
 
 func (f *Function) Arity() int {
 	return len(f.declaration.Parameters)
 }
 
-func (f *Function) Call(itp *Interpreter, args []interface{}) interface{} {
-	env := NewEnvWithEnclosing(itp.GetGlobalEnv())
+func (f *Function) Call(itp *Interpreter, args []interface{}) (result interface{}) {
+	env := NewEnvWithEnclosing(f.closure)
 
 	for i, param := range f.declaration.Parameters {
 		env.Define(param.Lexeme, args[i])
 	}
 
+	if f.declaration.Generator {
+		return newGenerator(itp, f, env)
+	}
+
+	itp.pushFrame(f.declaration.Name.Lexeme, env)
+	defer itp.popFrame()
+
+	if hook, ok := itp.hook.(CallHook); ok {
+		hook.EnterFunction(itp, f)
+		defer hook.ExitFunction(itp, f)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			ret, ok := r.(*returnValue)
+			if !ok {
+				panic(r)
+			}
+			result = ret.value
+		}
+	}()
+
 	itp.ExecuteBlock(f.declaration.Body, env)
 	return nil
 }
 
+// Name 函数声明的名字
+func (f *Function) Name() string {
+	return f.declaration.Name.Lexeme
+}
+
 func (f *Function) String() string {
 	return "<fn " + f.declaration.Name.Lexeme + ">"
 }
+
+// returnValue return 语句通过 panic 把返回值带回 Function.Call
+type returnValue struct {
+	value interface{}
+}
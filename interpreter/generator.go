@@ -0,0 +1,203 @@
+package interpreter
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Generator 调用包含 yield 的函数得到的值
+//
+// 函数体在另一个 goroutine 中由 fork 出的解释器执行, 每次 next() 运行到下一个 yield 为止,
+// 两个 goroutine 通过 channel 交替运行, 任何时候只有一个在执行 Lox 代码.
+// 恢复执行时生成器的调用栈接在调用者的调用栈上, 挂起时不引用调用者,
+// 所以不再被引用的生成器可以在垃圾回收时被关闭, 挂起的 goroutine 随之退出.
+// 每次恢复执行前后调用 CallHook, 所以函数体的时间记在生成器函数上, 每次恢复算一次调用
+type Generator struct {
+	*coroutine
+}
+
+type coroutine struct {
+	fn      *Function
+	name    string
+	itp     *Interpreter
+	frame   *Frame
+	body    []expr.Stmt
+	env     *Env
+	mu      sync.Mutex // 保护 running 和 done, started 只在 running 时访问
+	started bool
+	running bool
+	done    bool
+
+	resume chan bool // true 继续执行, false 关闭
+	yield  chan yieldResult
+}
+
+type yieldResult struct {
+	value interface{}
+	done  bool
+	err   interface{} // 函数体中的 panic, 在调用 next() 的 goroutine 中重新 panic
+}
+
+// generatorClosed 关闭挂起的生成器时在 yield 处 panic, 使函数体退出
+type generatorClosed struct{}
+
+func newGenerator(itp *Interpreter, fn *Function, env *Env) *Generator {
+	name := fn.Name()
+	co := &coroutine{
+		fn:     fn,
+		name:   name,
+		frame:  &Frame{Name: name, Env: env},
+		body:   fn.declaration.Body,
+		env:    env,
+		resume: make(chan bool),
+		yield:  make(chan yieldResult),
+	}
+	co.itp = itp.fork(env)
+	co.itp.coroutine = co
+
+	g := &Generator{co}
+	runtime.SetFinalizer(g, func(g *Generator) {
+		g.close()
+	})
+	return g
+}
+
+func (co *coroutine) run() {
+	result := yieldResult{done: true}
+	defer func() {
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case *returnValue, generatorClosed:
+			default:
+				result.err = r
+			}
+		}
+		co.yield <- result
+	}()
+
+	co.itp.executeBlock(co.body, co.env)
+}
+
+// next 在 itp 中运行到下一个 yield, 函数体结束时 ok 为 false
+func (co *coroutine) next(itp *Interpreter) (value interface{}, ok bool) {
+	if !co.acquire() {
+		return nil, false
+	}
+
+	co.itp.frames = append(itp.frames[:len(itp.frames):len(itp.frames)], co.frame)
+	hook, _ := co.itp.hook.(CallHook)
+	if hook != nil {
+		hook.EnterFunction(co.itp, co.fn)
+	}
+	if co.started {
+		co.resume <- true
+	} else {
+		co.started = true
+		go co.run()
+	}
+	r := <-co.yield
+	if hook != nil {
+		hook.ExitFunction(co.itp, co.fn)
+	}
+	co.itp.frames = nil
+	co.release(r.err != nil || r.done)
+
+	if r.err != nil {
+		panic(r.err)
+	}
+	return r.value, !r.done
+}
+
+// close 结束挂起的函数体, 之后 next() 总是返回 nil
+func (co *coroutine) close() {
+	if !co.acquire() {
+		return
+	}
+
+	if co.started {
+		co.itp.frames = []*Frame{co.frame}
+		co.resume <- false
+		<-co.yield
+		co.itp.frames = nil
+	}
+	co.release(true)
+}
+
+// acquire 标记生成器正在运行, 已经结束时返回 false; 不同任务同时恢复同一个生成器时后来的出错
+func (co *coroutine) acquire() bool {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	if co.running {
+		panic(lox.NewRuntimeError(nil, "Generator is already running."))
+	}
+	if co.done {
+		return false
+	}
+	co.running = true
+	return true
+}
+
+func (co *coroutine) release(done bool) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	co.running = false
+	co.done = done
+}
+
+func (co *coroutine) isDone() bool {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return co.done
+}
+
+// Next 实现 Iterator
+func (g *Generator) Next(itp *Interpreter) (interface{}, bool) {
+	return g.next(itp)
+}
+
+// Iterator 实现 Iterable, 生成器就是它自己的迭代器
+func (g *Generator) Iterator() Iterator {
+	return g
+}
+
+// Get next() 返回下一个值, 结束后返回 nil; done 为 next() 是否已经发现函数体结束; close() 提前结束
+func (g *Generator) Get(name *token.Token) interface{} {
+	switch name.Lexeme {
+	case "next":
+		return NewNativeFunction("next", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			value, _ := g.next(itp)
+			return value
+		})
+	case "close":
+		return NewNativeFunction("close", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			g.close()
+			return nil
+		})
+	case "done":
+		return g.isDone()
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (g *Generator) String() string {
+	return "<generator " + g.name + ">"
+}
+
+func (itp *Interpreter) VisitorYieldStmtExpr(stmt *expr.Yield) interface{} {
+	var value interface{}
+	if stmt.Value != nil {
+		value = itp.evaluate(stmt.Value)
+	}
+
+	itp.coroutine.yield <- yieldResult{value: value}
+	if !<-itp.coroutine.resume {
+		panic(generatorClosed{})
+	}
+	return nil
+}
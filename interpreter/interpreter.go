@@ -2,34 +2,169 @@ package interpreter
 
 import (
 	"fmt"
+	"io"
+	"math/big"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/zhiruchen/lox-go/expr"
 	"github.com/zhiruchen/lox-go/lox"
 	"github.com/zhiruchen/lox-go/token"
 )
 
+// MaxCallDepth 调用栈的最大深度, 超过时报告 Stack overflow. 而不是让 Go 的栈溢出
+const MaxCallDepth = 1024
+
 // Interpreter the lox lang interpreter
+//
+// 一个 Interpreter 同一时间只能在一个 goroutine 中使用; 不同的 Interpreter 可以并发运行,
+// 并且可以通过 NewInterpreterWithGlobals 共享同一个冻结的全局环境
 type Interpreter struct {
-	env     *Env
-	globals *Env
+	env       *Env
+	globals   *Env
+	out       io.Writer
+	reporter  *lox.Reporter
+	hook      Hook
+	frames    []*Frame
+	group     *group
+	task      *Task
+	proc      *process
+	stepLimit int
+
+	coroutine *coroutine // 正在执行的生成器, 只在 fork 出的解释器中不为 nil
+
+	printing map[*Map]bool // 正在转换为字符串的映射, 包含自身的映射输出为 {...}
 }
 
+// NewInterpreter 不授予任何能力的解释器, fs, os, time, net, env 和 clock 都不可用;
+// 需要时使用 NewInterpreterWithConfig, 例如 NewInterpreterWithConfig(FullAccess())
 func NewInterpreter() *Interpreter {
+	return NewInterpreterWithConfig(&Config{})
+}
+
+// NewInterpreterWithConfig 只安装 cfg 授予的内置模块的解释器
+func NewInterpreterWithConfig(cfg *Config) *Interpreter {
+	globals := NewEnv()
+	defineBuiltins(globals, cfg)
+	return newInterpreter(globals)
+}
+
+// NewInterpreterWithGlobals 全局作用域的外层为 shared, 通常是 NewGlobals 返回的冻结的环境;
+// 脚本中的全局声明只定义在这个解释器自己的全局作用域中
+func NewInterpreterWithGlobals(shared *Env) *Interpreter {
+	return newInterpreter(NewEnvWithEnclosing(shared))
+}
+
+// NewGlobals 包含 cfg 授予的内置模块和 prelude 中的声明的冻结的全局环境, 可以被多个解释器同时使用
+func NewGlobals(prelude []expr.Stmt, cfg *Config) (*Env, error) {
+	globals := NewEnv()
+	defineBuiltins(globals, cfg)
+
+	if err := newInterpreter(globals).Run(prelude); err != nil {
+		return nil, err
+	}
+	globals.Freeze()
+	return globals, nil
+}
+
+// Builtins cfg 授予的内置全局函数和模块, 不包括没有授予的能力; 供语言服务器等工具使用
+func Builtins(cfg *Config) map[string]interface{} {
 	globals := NewEnv()
-	globals.Define("clock", &CLock{})
+	defineBuiltins(globals, cfg)
+
+	values := globals.Values()
+	for name, v := range values {
+		if _, ok := v.(*denied); ok {
+			delete(values, name)
+		}
+	}
+	return values
+}
+
+func defineBuiltins(globals *Env, cfg *Config) {
+	installModules(globals, cfg)
+	globals.Define("math", mathModule())
+	globals.Define("path", pathModule())
+	globals.Define("BigInt", NewNativeFunction("BigInt", 1, bigIntOf))
+	globals.Define("Decimal", NewNativeFunction("Decimal", 1, decimalOf))
+	globals.Define("range", NewNativeFunction("range", -1, rangeOf))
+	globals.Define("isError", NewNativeFunction("isError", 1, isError))
+	globals.Define("channel", NewNativeFunction("channel", -1, channelOf))
+}
+
+func newInterpreter(globals *Env) *Interpreter {
+	return &Interpreter{
+		env:      globals,
+		globals:  globals,
+		out:      os.Stdout,
+		reporter: lox.NewReporter(os.Stderr),
+		frames:   []*Frame{{Name: "<script>", Env: globals}},
+		group:    newGroup(&sync.Mutex{}),
+		task:     &Task{},
+		proc:     newProcess(),
+	}
+}
 
-	return &Interpreter{env: globals, globals: globals}
+// fork 与 itp 共享全局环境, 输出, Reporter, 钩子, 任务组, 任务, process 和步数限制的新解释器, 调用栈由调用者设置
+func (itp *Interpreter) fork(env *Env) *Interpreter {
+	return &Interpreter{
+		env:       env,
+		globals:   itp.globals,
+		out:       itp.out,
+		reporter:  itp.reporter,
+		hook:      itp.hook,
+		group:     itp.group,
+		task:      itp.task,
+		proc:      itp.proc,
+		stepLimit: itp.stepLimit,
+	}
 }
 
-// Interpret 运行解释器
-func (itp *Interpreter) Interpret(statements []expr.Stmt) {
-	//fmt.Println(itp.stringify(itp.evaluate(exp)))
+// SetOutput print 语句的输出位置, 默认为 os.Stdout
+func (itp *Interpreter) SetOutput(out io.Writer) {
+	itp.out = out
+}
 
+// SetReporter 报告这个解释器运行的脚本的错误, 默认为这个解释器自己的向 os.Stderr 输出的 Reporter
+func (itp *Interpreter) SetReporter(reporter *lox.Reporter) {
+	itp.reporter = reporter
+}
+
+// Reporter 见 SetReporter; 并发运行的解释器各自使用自己的 Reporter, HadError 等状态互不影响
+func (itp *Interpreter) Reporter() *lox.Reporter {
+	return itp.reporter
+}
+
+// interpret 运行解释器, 然后等待 spawn 出的所有任务结束; 运行时错误以 panic 的形式传出
+func (itp *Interpreter) interpret(statements []expr.Stmt) {
 	for _, statement := range statements {
 		itp.execute(statement)
 	}
+	itp.join()
+}
+
+// Run 运行解释器, 然后等待 spawn 出的所有任务结束; 运行时错误作为 error 返回, 不会 panic
+func (itp *Interpreter) Run(statements []expr.Stmt) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			itp.env = itp.globals
+			itp.frames = itp.frames[:1]
+			itp.abandon(r)
+			err = toError(r)
+		}
+	}()
+
+	itp.interpret(statements)
+	return nil
+}
+
+// SetStepLimit 最多执行 limit 条语句 (包括 spawn 出的任务), 超过时产生运行时错误; 0 表示不限制
+func (itp *Interpreter) SetStepLimit(limit int) {
+	atomic.StoreInt64(&itp.group.steps, 0)
+	itp.stepLimit = limit
 }
 
 func (itp *Interpreter) GetGlobalEnv() *Env {
@@ -37,6 +172,20 @@ func (itp *Interpreter) GetGlobalEnv() *Env {
 }
 
 func (itp *Interpreter) execute(stmt expr.Stmt) {
+	itp.checkAbandoned()
+	if itp.stepLimit > 0 {
+		if atomic.AddInt64(&itp.group.steps, 1) > int64(itp.stepLimit) {
+			panic(lox.NewRuntimeError(nil, "Step limit exceeded."))
+		}
+	}
+
+	frame := itp.frames[len(itp.frames)-1]
+	frame.Line = stmt.Line()
+	frame.Env = itp.env
+
+	if itp.hook != nil {
+		itp.hook.BeforeExecute(itp, stmt)
+	}
 	stmt.Accept(itp)
 }
 
@@ -63,41 +212,46 @@ func (itp *Interpreter) executeBlock(statements []expr.Stmt, env *Env) {
 
 func (itp *Interpreter) VisitorBinaryExpr(exp *expr.Binary) interface{} {
 	left, right := itp.evaluate(exp.Left), itp.evaluate(exp.Right)
+	return itp.binary(exp.Operator, left, right)
+}
 
-	switch exp.Operator.TokenType {
-	case token.Minus:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 - v2
+// binary 对已经求值的操作数进行二元运算, 复合赋值也使用它
+func (itp *Interpreter) binary(operator *token.Token, left, right interface{}) interface{} {
+	switch operator.TokenType {
+	case token.Minus, token.Star:
+		return arithmetic(operator, left, right)
 	case token.Slash:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 / v2
-	case token.Star:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 * v2
+		return divide(operator, left, right)
+	case token.TildeSlash:
+		return intDivide(operator, left, right)
+	case token.Percent:
+		return modulo(operator, left, right)
+	case token.StarStar:
+		return power(operator, left, right)
+	case token.Ampersand, token.Pipe, token.Caret:
+		return bitwise(operator, left, right)
+	case token.LessLess, token.GreaterGreater:
+		return shift(operator, left, right)
 	case token.Plus:
-		v, ok := left.(float64)
-		v1, ok1 := right.(float64)
-		if ok && ok1 {
-			return v + v1
+		if isNumber(left) && isNumber(right) {
+			return arithmetic(operator, left, right)
 		}
 		v2, ok2 := left.(string)
 		v3, ok3 := right.(string)
 		if ok2 && ok3 {
 			return v2 + v3
 		}
-		panic(fmt.Sprintf("%s Operands must be two numbers or two strings!", exp.Operator.Lexeme))
+		panic(lox.NewRuntimeError(operator, "Operands must be two numbers or two strings."))
 	case token.Greater:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 > v2
+		return compare(operator, left, right) == 1
 	case token.GreaterEqual:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 >= v2
+		c := compare(operator, left, right)
+		return c == 1 || c == 0
 	case token.Less:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 < v2
+		return compare(operator, left, right) == -1
 	case token.LessEqual:
-		v1, v2 := itp.checkNumberOperands(*exp.Operator, left, right)
-		return v1 <= v2
+		c := compare(operator, left, right)
+		return c == -1 || c == 0
 	case token.EqualEqual:
 		return itp.isEqual(left, right)
 	case token.BangEqual:
@@ -109,24 +263,120 @@ func (itp *Interpreter) VisitorBinaryExpr(exp *expr.Binary) interface{} {
 
 func (itp *Interpreter) VisitorCallExpr(expr *expr.Call) interface{} {
 	callee := itp.evaluate(expr.Callee)
+	arguments := itp.arguments(expr.Arguments)
+
+	return itp.call(expr.Paren, itp.callable(expr.Paren, callee, arguments), arguments)
+}
 
+func (itp *Interpreter) arguments(exps []expr.Expr) []interface{} {
 	var arguments []interface{}
-	for _, arg := range expr.Arguments {
+	for _, arg := range exps {
 		arguments = append(arguments, itp.evaluate(arg))
 	}
+	return arguments
+}
 
+// callable 检查 callee 是否可以用 arguments 调用
+func (itp *Interpreter) callable(paren *token.Token, callee interface{}, arguments []interface{}) Callable {
 	function, ok := callee.(Callable)
 	if !ok {
-		panic(lox.NewRuntimeError(expr.Paren, "Can only call functions and classes"))
+		panic(lox.NewRuntimeError(paren, "Can only call functions and classes."))
+	}
+
+	if arity := function.Arity(); arity >= 0 && len(arguments) != arity {
+		panic(lox.NewRuntimeError(paren, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments))))
+	}
+	return function
+}
+
+func (itp *Interpreter) call(paren *token.Token, function Callable, arguments []interface{}) interface{} {
+	if itp.Depth() >= MaxCallDepth {
+		panic(lox.NewRuntimeError(paren, "Stack overflow."))
 	}
 
-	if len(arguments) != function.Arity() {
-		panic(lox.RuntimeError{expr.Paren, fmt.Sprintf("Expected %d arguments but got %d", function.Arity(), len(arguments))})
+	switch function.(type) {
+	case *NativeFunction, *denied:
+		// 内置函数报告的错误没有位置, 使用调用处的右括号
+		defer func() {
+			if r := recover(); r != nil {
+				if e, ok := r.(*lox.RuntimeError); ok && e.Tk == nil {
+					e.Tk = paren
+				}
+				panic(r)
+			}
+		}()
 	}
 
 	return function.Call(itp, arguments)
 }
 
+func (itp *Interpreter) VisitorInterpolationExpr(exp *expr.Interpolation) interface{} {
+	var b strings.Builder
+	for _, part := range exp.Parts {
+		b.WriteString(itp.stringify(itp.evaluate(part)))
+	}
+	return b.String()
+}
+
+// Object 有属性的内置值, 例如生成器
+type Object interface {
+	Get(name *token.Token) interface{}
+}
+
+// Setter 可以设置属性的值, 例如映射
+type Setter interface {
+	Set(name *token.Token, value interface{})
+}
+
+func (itp *Interpreter) VisitorSetExpr(exp *expr.Set) interface{} {
+	target := itp.evaluate(exp.Object)
+	object, ok := target.(Setter)
+	if !ok {
+		panic(lox.NewRuntimeError(exp.Name, "Only maps have fields."))
+	}
+
+	// 与变量的复合赋值一样, 先读取旧值再对右边求值
+	var old interface{}
+	if exp.Operator != nil {
+		old = getField(object, exp.Name)
+	}
+	value := itp.evaluate(exp.Value)
+	if exp.Operator != nil {
+		value = itp.binary(exp.Operator, old, value)
+	}
+	object.Set(exp.Name, value)
+	return value
+}
+
+// getField 复合赋值和 ++/-- 读取属性的旧值, 宿主提供的 Setter 可能不能读取
+func getField(object Setter, name *token.Token) interface{} {
+	getter, ok := object.(Object)
+	if !ok {
+		panic(lox.NewRuntimeError(name, "Cannot read property '"+name.Lexeme+"'."))
+	}
+	return getter.Get(name)
+}
+
+func (itp *Interpreter) VisitorGetExpr(exp *expr.Get) interface{} {
+	switch object := itp.evaluate(exp.Object).(type) {
+	case Object:
+		return object.Get(exp.Name)
+	case string:
+		return stringMethod(exp.Name, object)
+	case int64, *big.Int, *big.Rat, float64:
+		return numberMethod(exp.Name, object)
+	}
+	panic(lox.NewRuntimeError(exp.Name, "Only instances have properties."))
+}
+
+func (itp *Interpreter) VisitorListExpr(exp *expr.List) interface{} {
+	elements := make([]interface{}, len(exp.Elements))
+	for i, e := range exp.Elements {
+		elements[i] = itp.evaluate(e)
+	}
+	return NewList(elements)
+}
+
 func (itp *Interpreter) VisitorGroupingExpr(exp *expr.Grouping) interface{} {
 	return itp.evaluate(exp.Expression)
 }
@@ -138,19 +388,33 @@ func (itp *Interpreter) VisitorLiteralExpr(exp *expr.Literal) interface{} {
 func (itp *Interpreter) VisitorLogicalExpr(expr *expr.Logical) interface{} {
 	left := itp.evaluate(expr.Left)
 
-	if expr.Operator.TokenType == token.OR {
-		if itp.isTruthy(left) {
-			return left
-		}
-	} else {
-		if !itp.isTruthy(left) {
-			return left
-		}
+	var shortCircuit bool
+	switch expr.Operator.TokenType {
+	case token.OR:
+		shortCircuit = itp.isTruthy(left)
+	case token.QuestionQuestion:
+		shortCircuit = left != nil
+	default:
+		shortCircuit = !itp.isTruthy(left)
 	}
+	itp.branch(expr, shortCircuit)
 
+	if shortCircuit {
+		return left
+	}
 	return itp.evaluate(expr.Right)
 }
 
+func (itp *Interpreter) VisitorConditionalExpr(exp *expr.Conditional) interface{} {
+	cond := itp.isTruthy(itp.evaluate(exp.Condition))
+	itp.branch(exp, cond)
+
+	if cond {
+		return itp.evaluate(exp.Then)
+	}
+	return itp.evaluate(exp.Else)
+}
+
 func (itp *Interpreter) VisitorUnaryExpr(exp *expr.Unary) interface{} {
 	right := itp.evaluate(exp.Right)
 
@@ -158,8 +422,9 @@ func (itp *Interpreter) VisitorUnaryExpr(exp *expr.Unary) interface{} {
 	case token.Bang:
 		return !itp.isTruthy(right)
 	case token.Minus:
-		v := itp.checkNumberOperand(*exp.Operator, right)
-		return 0 - v
+		return negate(exp.Operator, right)
+	case token.Tilde:
+		return bitNot(exp.Operator, right)
 	}
 	return nil
 }
@@ -174,13 +439,16 @@ func (itp *Interpreter) VisitorExpressionStmtExpr(expr *expr.Expression) interfa
 }
 
 func (itp *Interpreter) VisitorFunStmtExpr(stmt *expr.Function) interface{} {
-	function := NewFunction(stmt)
+	function := NewFunction(stmt, itp.env)
 	itp.env.Define(stmt.Name.Lexeme, function)
 	return nil
 }
 
 func (itp *Interpreter) VisitorIFStmtExpr(stmt *expr.IF) interface{} {
-	if itp.isTruthy(itp.evaluate(stmt.Condition)) {
+	cond := itp.isTruthy(itp.evaluate(stmt.Condition))
+	itp.branch(stmt, cond)
+
+	if cond {
 		itp.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
 		itp.execute(stmt.ElseBranch)
@@ -191,10 +459,39 @@ func (itp *Interpreter) VisitorIFStmtExpr(stmt *expr.IF) interface{} {
 
 func (itp *Interpreter) VisitorPrintStmtExpr(expr *expr.Print) interface{} {
 	value := itp.evaluate(expr.Print)
-	fmt.Printf("%s\n", itp.stringify(value))
+
+	itp.group.out.Lock()
+	defer itp.group.out.Unlock()
+	fmt.Fprintf(itp.out, "%s\n", itp.stringify(value))
 	return nil
 }
 
+func (itp *Interpreter) VisitorAssertStmtExpr(stmt *expr.Assert) interface{} {
+	if itp.isTruthy(itp.evaluate(stmt.Condition)) {
+		return nil
+	}
+
+	msg := "Assertion failed."
+	if stmt.Message != nil {
+		msg = "Assertion failed: " + itp.stringify(itp.evaluate(stmt.Message))
+	}
+	panic(lox.NewRuntimeError(stmt.Keyword, msg))
+}
+
+// VisitorTestStmtExpr 普通执行时跳过测试, 由 testrunner 单独运行
+func (itp *Interpreter) VisitorTestStmtExpr(stmt *expr.Test) interface{} {
+	return nil
+}
+
+func (itp *Interpreter) VisitorReturnStmtExpr(stmt *expr.Return) interface{} {
+	var value interface{}
+	if stmt.Value != nil {
+		value = itp.evaluate(stmt.Value)
+	}
+
+	panic(&returnValue{value: value})
+}
+
 func (itp *Interpreter) VisitorVarStmtExpr(expr *expr.Var) interface{} {
 	var value interface{}
 
@@ -206,36 +503,163 @@ func (itp *Interpreter) VisitorVarStmtExpr(expr *expr.Var) interface{} {
 	return nil
 }
 
-func (itp *Interpreter) VisitorWhileStmtExpr(stmt *expr.While) interface{} {
-	for itp.isTruthy(itp.evaluate(stmt.Condition)) {
-		itp.execute(stmt.Body)
+// VisitorMatchStmtExpr 依次尝试每个 case, 只执行第一个匹配的; 没有匹配时什么也不做
+func (itp *Interpreter) VisitorMatchStmtExpr(stmt *expr.Match) interface{} {
+	value := itp.evaluate(stmt.Value)
+
+	for _, c := range stmt.Cases {
+		env := itp.matchCase(c, value)
+		itp.branch(c, env != nil)
+
+		if env != nil {
+			itp.executeBlock([]expr.Stmt{c.Body}, env)
+			return nil
+		}
 	}
+	return nil
+}
 
+// matchCase 匹配时返回包含模式中绑定的变量的环境, 否则返回 nil
+func (itp *Interpreter) matchCase(c *expr.Case, value interface{}) *Env {
+	for _, pattern := range c.Patterns {
+		env := NewEnvWithEnclosing(itp.env)
+		if !itp.matchPattern(pattern, value, env) {
+			continue
+		}
+		if c.Guard == nil {
+			return env
+		}
+
+		if itp.isTruthy(itp.evaluateIn(c.Guard, env)) {
+			return env
+		}
+	}
 	return nil
 }
 
-func (itp *Interpreter) VisitorAssignExpr(expr *expr.Assign) interface{} {
-	value := itp.evaluate(expr.Value)
+func (itp *Interpreter) matchPattern(pattern expr.Pattern, value interface{}, env *Env) bool {
+	switch p := pattern.(type) {
+	case *expr.WildcardPattern:
+		return true
+	case *expr.BindingPattern:
+		env.Define(p.Name.Lexeme, value)
+		return true
+	case *expr.LiteralPattern:
+		return itp.isEqual(itp.evaluate(p.Value), value)
+	case *expr.ListPattern:
+		list, ok := value.(*List)
+		if !ok || len(list.Elements) != len(p.Elements) {
+			return false
+		}
+		for i, element := range p.Elements {
+			if !itp.matchPattern(element, list.Elements[i], env) {
+				return false
+			}
+		}
+		return true
+	case *expr.ShapePattern:
+		if _, ok := value.(Object); !ok {
+			return false
+		}
+		for i, name := range p.Names {
+			field, ok := property(value, name)
+			if !ok || !itp.matchPattern(p.Fields[i], field, env) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
 
-	itp.env.Assign(expr.Name, value)
-	return value
+// property 映射中键 name 的值或者内置对象的属性 name, 没有时返回 false
+func property(value interface{}, name *token.Token) (v interface{}, ok bool) {
+	switch object := value.(type) {
+	case *Map:
+		return object.Lookup(name.Lexeme)
+	case Object:
+		defer func() {
+			if r := recover(); r != nil {
+				if _, isRuntimeError := r.(*lox.RuntimeError); !isRuntimeError {
+					panic(r)
+				}
+				v, ok = nil, false
+			}
+		}()
+		return object.Get(name), true
+	}
+	return nil, false
 }
 
-func (itp *Interpreter) checkNumberOperand(operator token.Token, obj interface{}) float64 {
-	v, ok := obj.(float64)
-	if !ok {
-		panic(fmt.Sprintf("%s Operand must be a number.", operator.Lexeme))
+// VisitorForInStmtExpr 每次迭代都在新的环境中定义循环变量, 闭包捕获的是各自的值
+func (itp *Interpreter) VisitorForInStmtExpr(stmt *expr.ForIn) interface{} {
+	it := itp.iterate(stmt.In, itp.evaluate(stmt.Iterable))
+
+	for {
+		value, ok := it.Next(itp)
+		itp.branch(stmt, ok)
+		if !ok {
+			return nil
+		}
+
+		env := NewEnvWithEnclosing(itp.env)
+		env.Define(stmt.Name.Lexeme, value)
+		itp.executeBlock([]expr.Stmt{stmt.Body}, env)
+	}
+}
+
+func (itp *Interpreter) VisitorWhileStmtExpr(stmt *expr.While) interface{} {
+	for {
+		cond := itp.isTruthy(itp.evaluate(stmt.Condition))
+		itp.branch(stmt, cond)
+		if !cond {
+			break
+		}
+		itp.execute(stmt.Body)
 	}
-	return v
+
+	return nil
 }
 
-func (itp *Interpreter) checkNumberOperands(operator token.Token, left, right interface{}) (float64, float64) {
-	v1, ok := left.(float64)
-	v2, ok1 := right.(float64)
-	if !ok || !ok1 {
-		panic(fmt.Sprintf("%s Operands must be numbers!", operator.Lexeme))
+func (itp *Interpreter) VisitorUpdateExpr(exp *expr.Update) interface{} {
+	var object Setter
+	var old interface{}
+	if exp.Object != nil {
+		target := itp.evaluate(exp.Object)
+		var ok bool
+		if object, ok = target.(Setter); !ok {
+			panic(lox.NewRuntimeError(exp.Name, "Only maps have fields."))
+		}
+		old = getField(object, exp.Name)
+	} else {
+		old = itp.env.Get(exp.Name)
+	}
+	if !isNumber(old) {
+		panic(lox.NewRuntimeError(exp.Operator, "Operand must be a number."))
+	}
+
+	op := &token.Token{TokenType: token.Plus, Lexeme: exp.Operator.Lexeme, Line: exp.Operator.Line, Column: exp.Operator.Column}
+	if exp.Operator.TokenType == token.MinusMinus {
+		op.TokenType = token.Minus
+	}
+	value := arithmetic(op, old, int64(1))
+	if object != nil {
+		object.Set(exp.Name, value)
+	} else {
+		itp.env.Assign(exp.Name, value)
 	}
-	return v1, v2
+
+	if exp.Prefix {
+		return value
+	}
+	return old
+}
+
+func (itp *Interpreter) VisitorAssignExpr(expr *expr.Assign) interface{} {
+	value := itp.evaluate(expr.Value)
+
+	itp.env.Assign(expr.Name, value)
+	return value
 }
 
 func (itp *Interpreter) isEqual(left, right interface{}) bool {
@@ -247,6 +671,9 @@ func (itp *Interpreter) isEqual(left, right interface{}) bool {
 		return false
 	}
 
+	if isNumber(left) && isNumber(right) {
+		return numbersEqual(left, right)
+	}
 	return left == right
 }
 
@@ -265,18 +692,51 @@ func (itp *Interpreter) evaluate(exp expr.Expr) interface{} {
 	return exp.Accept(itp)
 }
 
-func (itp *Interpreter) stringify(obj interface{}) string {
-	if obj == nil {
-		return "nil"
-	}
+func (itp *Interpreter) evaluateIn(exp expr.Expr, env *Env) interface{} {
+	previous := itp.env
+	defer func() {
+		itp.env = previous
+	}()
 
-	v, ok := obj.(float64)
-	if ok {
-		text := strconv.FormatFloat(v, 'f', 6, 64)
-		if strings.HasSuffix(text, ".0") {
-			text = text[0 : len(text)-2]
-			return text
+	itp.env = env
+	return itp.evaluate(exp)
+}
+
+// Evaluate 在 env 中对表达式求值, 运行时错误作为 error 返回
+func (itp *Interpreter) Evaluate(exp expr.Expr, env *Env) (result interface{}, err error) {
+	previous := itp.env
+	defer func() {
+		itp.env = previous
+		if r := recover(); r != nil {
+			result, err = nil, toError(r)
 		}
+	}()
+
+	itp.env = env
+	return itp.evaluate(exp), nil
+}
+
+// Stringify 值的字符串表示, 与 print 语句一致
+func (itp *Interpreter) Stringify(obj interface{}) string {
+	return itp.stringify(obj)
+}
+
+func (itp *Interpreter) stringify(obj interface{}) string {
+	switch v := obj.(type) {
+	case nil:
+		return "nil"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	case *big.Int:
+		return v.String()
+	case *big.Rat:
+		return formatDecimal(v)
+	case *List:
+		return itp.stringifyList(v)
+	case *Map:
+		return itp.stringifyMap(v)
 	}
 	return fmt.Sprintf("%v", obj)
 }
@@ -0,0 +1,48 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// writeOnly 宿主提供的只能设置不能读取属性的值
+type writeOnly struct {
+	values map[string]interface{}
+}
+
+func (w *writeOnly) Set(name *token.Token, value interface{}) {
+	w.values[name.Lexeme] = value
+}
+
+func TestWriteOnlyTarget(t *testing.T) {
+	reporter := lox.NewReporter(&bytes.Buffer{})
+	w := &writeOnly{values: map[string]interface{}{}}
+
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"w.x = 1;", ""},
+		{"w.x += 1;", "Cannot read property 'x'."},
+		{"w.x++;", "Cannot read property 'x'."},
+	}
+	for _, tt := range tests {
+		itp := interpreter.NewInterpreter()
+		itp.GetGlobalEnv().Define("w", w)
+
+		err := itp.Run(parse(reporter, tt.source))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.source, err, tt.err)
+		}
+		if _, ok := err.(*lox.RuntimeError); err != nil && !ok {
+			t.Errorf("%s: got %T, want a runtime error", tt.source, err)
+		}
+	}
+	if w.values["x"] != int64(1) {
+		t.Errorf("got x = %v, want 1", w.values["x"])
+	}
+}
@@ -0,0 +1,142 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Iterator for-in 每次迭代在 itp 中调用一次 Next, ok 为 false 时结束
+type Iterator interface {
+	Next(itp *Interpreter) (value interface{}, ok bool)
+}
+
+// Iterable 可以用 for-in 遍历的值
+type Iterable interface {
+	Iterator() Iterator
+}
+
+// iterate 列表按元素, 字符串按字符 (rune), 映射按键遍历; 有 iterator 的映射使用迭代器协议
+func (itp *Interpreter) iterate(in *token.Token, value interface{}) Iterator {
+	switch v := value.(type) {
+	case *Map:
+		if fn, ok := v.Lookup("iterator"); ok {
+			return itp.userIterator(in, fn)
+		}
+		return v.Iterator()
+	case Iterable:
+		return v.Iterator()
+	case *List:
+		i := 0
+		return iteratorFunc(func() (interface{}, bool) {
+			if i >= len(v.Elements) {
+				return nil, false
+			}
+			i++
+			return v.Elements[i-1], true
+		})
+	case string:
+		runes := []rune(v)
+		return iteratorFunc(func() (interface{}, bool) {
+			if len(runes) == 0 {
+				return nil, false
+			}
+			r := runes[0]
+			runes = runes[1:]
+			return string(r), true
+		})
+	}
+	panic(lox.NewRuntimeError(in, "Can only iterate over lists, maps, strings, ranges and generators."))
+}
+
+// userIterator 迭代器协议: iterator() 返回的对象有方法 next() 和属性 done,
+// 在每次调用 next() 的前后检查 done, 为真时结束, 所以生成器也是合法的迭代器
+type userIterator struct {
+	in     *token.Token
+	object Object
+}
+
+func (itp *Interpreter) userIterator(in *token.Token, fn interface{}) Iterator {
+	object, ok := itp.call(in, itp.callable(in, fn, nil), nil).(Object)
+	if !ok {
+		panic(lox.NewRuntimeError(in, "iterator() must return an object with next() and done."))
+	}
+	return &userIterator{in: in, object: object}
+}
+
+func (it *userIterator) Next(itp *Interpreter) (interface{}, bool) {
+	if it.done(itp) {
+		return nil, false
+	}
+	next := it.object.Get(it.property("next"))
+	value := itp.call(it.in, itp.callable(it.in, next, nil), nil)
+	if it.done(itp) {
+		return nil, false
+	}
+	return value, true
+}
+
+func (it *userIterator) done(itp *Interpreter) bool {
+	return itp.isTruthy(it.object.Get(it.property("done")))
+}
+
+// property 用于报告错误的属性名 token, 位置为 for-in 的 in
+func (it *userIterator) property(name string) *token.Token {
+	return &token.Token{TokenType: token.Identifier, Lexeme: name, Line: it.in.Line, Column: it.in.Column}
+}
+
+type iteratorFunc func() (interface{}, bool)
+
+func (f iteratorFunc) Next(itp *Interpreter) (interface{}, bool) {
+	return f()
+}
+
+// Range range(stop), range(start, stop) 或者 range(start, stop, step) 的值, 不包含 stop
+type Range struct {
+	Start, Stop, Step int64
+}
+
+func (r *Range) Iterator() Iterator {
+	next, done := r.Start, false
+	return iteratorFunc(func() (interface{}, bool) {
+		if done || (r.Step > 0 && next >= r.Stop) || (r.Step < 0 && next <= r.Stop) {
+			return nil, false
+		}
+		value := next
+		// 下一个值溢出时一定已经越过了 stop
+		next += r.Step
+		done = (r.Step > 0) != (next > value)
+		return value, true
+	})
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+func rangeOf(itp *Interpreter, args []interface{}) interface{} {
+	if len(args) < 1 || len(args) > 3 {
+		panic(lox.NewRuntimeError(nil, fmt.Sprintf("Expected 1 to 3 arguments but got %d.", len(args))))
+	}
+
+	var bounds [3]int64
+	for i, arg := range args {
+		n, ok := integerValue(arg)
+		if !ok {
+			panic(lox.NewRuntimeError(nil, "range() arguments must be integers."))
+		}
+		bounds[i] = n
+	}
+
+	switch len(args) {
+	case 1:
+		return &Range{Start: 0, Stop: bounds[0], Step: 1}
+	case 2:
+		return &Range{Start: bounds[0], Stop: bounds[1], Step: 1}
+	}
+	if bounds[2] == 0 {
+		panic(lox.NewRuntimeError(nil, "range() step cannot be zero."))
+	}
+	return &Range{Start: bounds[0], Stop: bounds[1], Step: bounds[2]}
+}
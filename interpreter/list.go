@@ -0,0 +1,22 @@
+package interpreter
+
+import (
+	"strings"
+)
+
+// List 列表字面量 [a, b] 的值, 按引用比较相等
+type List struct {
+	Elements []interface{}
+}
+
+func NewList(elements []interface{}) *List {
+	return &List{Elements: elements}
+}
+
+func (itp *Interpreter) stringifyList(list *List) string {
+	parts := make([]string, len(list.Elements))
+	for i, e := range list.Elements {
+		parts[i] = itp.stringify(e)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
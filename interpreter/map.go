@@ -0,0 +1,243 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Map 映射字面量 {k: v} 的值, 按插入的顺序遍历, 按引用比较相等
+//
+// m.name 先查找内置方法 get, set, has, remove, keys, values 和 len, 再查找字符串键 name;
+// m.name = v 设置字符串键 name. spawn 出的任务可能同时修改同一个映射, 所以由 mu 保护
+type Map struct {
+	mu      sync.Mutex
+	entries []*mapEntry
+	index   map[interface{}]*mapEntry
+	frozen  bool
+}
+
+type mapEntry struct {
+	key, value interface{}
+}
+
+// numberKey 数值相等的数字是同一个键, 1, 1.0, 1n 和 1m 都对应 "1"
+type numberKey string
+
+func NewMap() *Map {
+	return &Map{index: make(map[interface{}]*mapEntry)}
+}
+
+func keyOf(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return numberKey(formatFloat(n))
+		}
+		return numberKey(exactRat(n).RatString())
+	case int64, *big.Int, *big.Rat:
+		return numberKey(toRat(n).RatString())
+	}
+	return v
+}
+
+// Lookup key 对应的值
+func (m *Map) Lookup(key interface{}) (interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.index[keyOf(key)]; ok {
+		return e.value, true
+	}
+	return nil, false
+}
+
+// Put 设置 key 对应的值, 冻结的映射产生运行时错误
+func (m *Map) Put(tk *token.Token, key, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.frozen {
+		panic(lox.NewRuntimeError(tk, "Cannot modify a frozen map."))
+	}
+	if e, ok := m.index[keyOf(key)]; ok {
+		e.value = value
+		return
+	}
+	e := &mapEntry{key: key, value: value}
+	m.entries = append(m.entries, e)
+	m.index[keyOf(key)] = e
+}
+
+func (m *Map) remove(key interface{}) interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.frozen {
+		panic(lox.NewRuntimeError(nil, "Cannot modify a frozen map."))
+	}
+	e, ok := m.index[keyOf(key)]
+	if !ok {
+		return nil
+	}
+	delete(m.index, keyOf(key))
+	for i, entry := range m.entries {
+		if entry == e {
+			m.entries = append(m.entries[:i:i], m.entries[i+1:]...)
+			break
+		}
+	}
+	return e.value
+}
+
+// Entries 键值对的拷贝, 按插入的顺序
+func (m *Map) Entries() (keys, values []interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.entries {
+		keys = append(keys, e.key)
+		values = append(values, e.value)
+	}
+	return keys, values
+}
+
+// Len 键的个数
+func (m *Map) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// Freeze 之后修改映射产生运行时错误
+func (m *Map) Freeze() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.frozen = true
+}
+
+// Frozen 是否已经冻结
+func (m *Map) Frozen() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.frozen
+}
+
+// Iterator 遍历开始时所有的键
+func (m *Map) Iterator() Iterator {
+	keys, _ := m.Entries()
+	return iteratorFunc(func() (interface{}, bool) {
+		if len(keys) == 0 {
+			return nil, false
+		}
+		k := keys[0]
+		keys = keys[1:]
+		return k, true
+	})
+}
+
+func (m *Map) Get(name *token.Token) interface{} {
+	switch name.Lexeme {
+	case "get":
+		// get(key, default) 没有 key 时返回 default, 省略时为 nil
+		return NewNativeFunction("get", -1, func(itp *Interpreter, args []interface{}) interface{} {
+			checkArgCount(args, 1, 2)
+			if v, ok := m.Lookup(args[0]); ok {
+				return v
+			}
+			if len(args) == 2 {
+				return args[1]
+			}
+			return nil
+		})
+	case "set":
+		return NewNativeFunction("set", 2, func(itp *Interpreter, args []interface{}) interface{} {
+			m.Put(nil, args[0], args[1])
+			return nil
+		})
+	case "has":
+		return NewNativeFunction("has", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			_, ok := m.Lookup(args[0])
+			return ok
+		})
+	case "remove":
+		// remove 返回删除的值, 没有 key 时返回 nil
+		return NewNativeFunction("remove", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return m.remove(args[0])
+		})
+	case "keys":
+		return NewNativeFunction("keys", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			keys, _ := m.Entries()
+			return NewList(keys)
+		})
+	case "values":
+		return NewNativeFunction("values", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			_, values := m.Entries()
+			return NewList(values)
+		})
+	case "len":
+		return NewNativeFunction("len", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			return int64(m.Len())
+		})
+	}
+
+	if v, ok := m.Lookup(name.Lexeme); ok {
+		return v
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (m *Map) Set(name *token.Token, value interface{}) {
+	m.Put(name, name.Lexeme, value)
+}
+
+func (itp *Interpreter) stringifyMap(m *Map) string {
+	if itp.printing[m] {
+		return "{...}"
+	}
+	if itp.printing == nil {
+		itp.printing = make(map[*Map]bool)
+	}
+	itp.printing[m] = true
+	defer delete(itp.printing, m)
+
+	keys, values := m.Entries()
+	parts := make([]string, len(keys))
+	for i := range keys {
+		parts[i] = itp.stringify(keys[i]) + ": " + itp.stringify(values[i])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func (itp *Interpreter) VisitorMapExpr(exp *expr.Map) interface{} {
+	m := NewMap()
+	for i := range exp.Keys {
+		key := itp.evaluate(exp.Keys[i])
+		m.Put(exp.Brace, key, itp.evaluate(exp.Values[i]))
+	}
+	return m
+}
+
+// freeze 冻结 v 和其中的映射, 被冻结的全局环境中的值不能再被修改
+func freeze(v interface{}) {
+	switch v := v.(type) {
+	case *Map:
+		if v.Frozen() {
+			return
+		}
+		v.Freeze()
+		_, values := v.Entries()
+		for _, value := range values {
+			freeze(value)
+		}
+	case *List:
+		for _, e := range v.Elements {
+			freeze(e)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/zhiruchen/lox-go/lox"
+)
+
+// mathModule 不需要任何能力, 总是安装为全局变量 math
+func mathModule() *Module {
+	members := map[string]interface{}{
+		"pi":  math.Pi,
+		"e":   math.E,
+		"inf": math.Inf(1),
+		"nan": math.NaN(),
+
+		"abs":   NewNativeFunction("abs", 1, mathAbs),
+		"floor": NewNativeFunction("floor", 1, rounding("floor", math.Floor, floorRat)),
+		"ceil":  NewNativeFunction("ceil", 1, rounding("ceil", math.Ceil, ceilRat)),
+		// round 0.5 远离 0 舍入
+		"round": NewNativeFunction("round", 1, rounding("round", math.Round, roundRat)),
+		"pow":   NewNativeFunction("pow", 2, mathPow),
+		"atan2": NewNativeFunction("atan2", 2, func(itp *Interpreter, args []interface{}) interface{} {
+			return math.Atan2(floatArg("atan2", args[0]), floatArg("atan2", args[1]))
+		}),
+		"min": NewNativeFunction("min", -1, extremum("min", -1)),
+		"max": NewNativeFunction("max", -1, extremum("max", 1)),
+
+		// 只有浮点数可能是 NaN 或者无穷大
+		"isNan": NewNativeFunction("isNan", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return math.IsNaN(floatArg("isNan", args[0]))
+		}),
+		"isFinite": NewNativeFunction("isFinite", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			f := floatArg("isFinite", args[0])
+			if _, ok := args[0].(float64); !ok {
+				return true
+			}
+			return !math.IsNaN(f) && !math.IsInf(f, 0)
+		}),
+	}
+
+	unary := map[string]func(float64) float64{
+		"sqrt": math.Sqrt,
+		"exp":  math.Exp,
+		"log":  math.Log,
+		"sin":  math.Sin,
+		"cos":  math.Cos,
+		"tan":  math.Tan,
+		"asin": math.Asin,
+		"acos": math.Acos,
+		"atan": math.Atan,
+	}
+	for name, fn := range unary {
+		name, fn := name, fn
+		members[name] = NewNativeFunction(name, 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return fn(floatArg(name, args[0]))
+		})
+	}
+
+	return NewModule("math", members)
+}
+
+// floatArg 参数必须是数字, 转换为浮点数
+func floatArg(fn string, v interface{}) float64 {
+	if !isNumber(v) {
+		panic(lox.NewRuntimeError(nil, fn+"() argument must be a number."))
+	}
+	return toFloat(v)
+}
+
+func mathAbs(itp *Interpreter, args []interface{}) interface{} {
+	switch n := args[0].(type) {
+	case int64:
+		if n >= 0 {
+			return n
+		}
+		if n == math.MinInt64 {
+			panic(lox.NewRuntimeError(nil, "Integer overflow."))
+		}
+		return -n
+	case *big.Int:
+		return new(big.Int).Abs(n)
+	case *big.Rat:
+		return new(big.Rat).Abs(n)
+	case float64:
+		return math.Abs(n)
+	}
+	panic(lox.NewRuntimeError(nil, "abs() argument must be a number."))
+}
+
+// rounding 整数原样返回, 十进制小数精确地舍入, 浮点数的结果仍是浮点数
+func rounding(name string, f func(float64) float64, r func(*big.Rat) *big.Int) func(itp *Interpreter, args []interface{}) interface{} {
+	return func(itp *Interpreter, args []interface{}) interface{} {
+		switch n := args[0].(type) {
+		case int64, *big.Int:
+			return n
+		case *big.Rat:
+			return new(big.Rat).SetInt(r(n))
+		case float64:
+			return f(n)
+		}
+		panic(lox.NewRuntimeError(nil, name+"() argument must be a number."))
+	}
+}
+
+func floorRat(r *big.Rat) *big.Int {
+	// big.Int.Div 是欧几里得除法, 分母为正时就是向下取整
+	return new(big.Int).Div(r.Num(), r.Denom())
+}
+
+func ceilRat(r *big.Rat) *big.Int {
+	return new(big.Int).Neg(floorRat(new(big.Rat).Neg(r)))
+}
+
+func roundRat(r *big.Rat) *big.Int {
+	half := big.NewRat(1, 2)
+	if r.Sign() < 0 {
+		return ceilRat(new(big.Rat).Sub(r, half))
+	}
+	return floorRat(new(big.Rat).Add(r, half))
+}
+
+// mathPow 与 ** 运算符相同, 只是参数不是数字时的错误消息不同
+func mathPow(itp *Interpreter, args []interface{}) interface{} {
+	floatArg("pow", args[0])
+	floatArg("pow", args[1])
+	return power(nil, args[0], args[1])
+}
+
+// extremum min 和 max, 返回原来的值而不是转换后的; 有 NaN 时结果是 NaN
+func extremum(name string, sign int) func(itp *Interpreter, args []interface{}) interface{} {
+	return func(itp *Interpreter, args []interface{}) interface{} {
+		if len(args) == 0 {
+			panic(lox.NewRuntimeError(nil, name+"() expects at least one argument."))
+		}
+
+		result := args[0]
+		for _, arg := range args {
+			if math.IsNaN(floatArg(name, arg)) {
+				return math.NaN()
+			}
+		}
+		for _, arg := range args[1:] {
+			k1, _ := kindOf(arg)
+			k2, _ := kindOf(result)
+			if compareNumbers(k1, k2, arg, result) == sign {
+				result = arg
+			}
+		}
+		return result
+	}
+}
@@ -0,0 +1,32 @@
+package interpreter
+
+import (
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Module 内置模块, 脚本通过 name.member 使用其中的函数和常量
+type Module struct {
+	name    string
+	members map[string]interface{}
+}
+
+func NewModule(name string, members map[string]interface{}) *Module {
+	return &Module{name: name, members: members}
+}
+
+// Name 模块的名字
+func (m *Module) Name() string {
+	return m.name
+}
+
+func (m *Module) Get(name *token.Token) interface{} {
+	if v, ok := m.members[name.Lexeme]; ok {
+		return v
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (m *Module) String() string {
+	return "<module " + m.name + ">"
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package interpreter
+
+// oNoFollow 这些平台没有 O_NOFOLLOW, 只依靠 checkPath 的检查
+const oNoFollow = 0
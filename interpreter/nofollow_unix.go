@@ -0,0 +1,8 @@
+//go:build unix
+
+package interpreter
+
+import "syscall"
+
+// oNoFollow 打开文件时不跟随最后一个部分的符号链接
+const oNoFollow = syscall.O_NOFOLLOW
@@ -0,0 +1,537 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Lox 的数字有四种, 按提升的顺序:
+//
+//	int64     整数字面量 123
+//	*big.Int  大整数 123n 或 BigInt(x)
+//	*big.Rat  精确的十进制小数 1.25m 或 Decimal(x)
+//	float64   带小数点或指数的字面量 1.5
+//
+// 混合运算时提升到两者中较高的一种; 大整数和浮点数运算得到浮点数,
+// 十进制小数不能和浮点数混合运算, 以免悄悄丢失精度.
+// int64 之间的 + - * 结果仍是 int64, 溢出时报错.
+
+type numKind int
+
+const (
+	kindInt numKind = iota
+	kindBig
+	kindDecimal
+	kindFloat
+)
+
+// DecimalPlaces 无限小数转换为字符串时保留的小数位数
+const DecimalPlaces = 28
+
+func kindOf(v interface{}) (numKind, bool) {
+	switch v.(type) {
+	case int64:
+		return kindInt, true
+	case *big.Int:
+		return kindBig, true
+	case *big.Rat:
+		return kindDecimal, true
+	case float64:
+		return kindFloat, true
+	}
+	return 0, false
+}
+
+func isNumber(v interface{}) bool {
+	_, ok := kindOf(v)
+	return ok
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f
+	case *big.Rat:
+		f, _ := n.Float64()
+		return f
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func toBig(v interface{}) *big.Int {
+	switch n := v.(type) {
+	case int64:
+		return big.NewInt(n)
+	case *big.Int:
+		return n
+	}
+	return nil
+}
+
+func toRat(v interface{}) *big.Rat {
+	switch n := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(n)
+	case *big.Int:
+		return new(big.Rat).SetInt(n)
+	case *big.Rat:
+		return n
+	case float64:
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(n, 'g', -1, 64))
+		return r
+	}
+	return nil
+}
+
+// checkNumbers 两个操作数都是数字, 返回运算使用的种类
+func checkNumbers(operator *token.Token, left, right interface{}) numKind {
+	k1, ok := kindOf(left)
+	k2, ok1 := kindOf(right)
+	if !ok || !ok1 {
+		panic(lox.NewRuntimeError(operator, "Operands must be numbers."))
+	}
+	if (k1 == kindDecimal && k2 == kindFloat) || (k1 == kindFloat && k2 == kindDecimal) {
+		panic(lox.NewRuntimeError(operator, "Cannot mix decimal and float operands, convert with Decimal()."))
+	}
+	if k2 > k1 {
+		return k2
+	}
+	return k1
+}
+
+// arithmetic + - *
+func arithmetic(operator *token.Token, left, right interface{}) interface{} {
+	switch checkNumbers(operator, left, right) {
+	case kindBig:
+		a, b := toBig(left), toBig(right)
+		switch operator.TokenType {
+		case token.Plus:
+			return new(big.Int).Add(a, b)
+		case token.Minus:
+			return new(big.Int).Sub(a, b)
+		default:
+			return new(big.Int).Mul(a, b)
+		}
+	case kindDecimal:
+		a, b := toRat(left), toRat(right)
+		switch operator.TokenType {
+		case token.Plus:
+			return new(big.Rat).Add(a, b)
+		case token.Minus:
+			return new(big.Rat).Sub(a, b)
+		default:
+			return new(big.Rat).Mul(a, b)
+		}
+	case kindFloat:
+		a, b := toFloat(left), toFloat(right)
+		switch operator.TokenType {
+		case token.Plus:
+			return a + b
+		case token.Minus:
+			return a - b
+		default:
+			return a * b
+		}
+	}
+
+	a, b := left.(int64), right.(int64)
+	var c int64
+	overflow := false
+	switch operator.TokenType {
+	case token.Plus:
+		c = a + b
+		overflow = (c > a) != (b > 0)
+	case token.Minus:
+		c = a - b
+		overflow = (c < a) != (b > 0)
+	default:
+		c = a * b
+		overflow = a != 0 && (c/a != b || (a == -1 && b == math.MinInt64))
+	}
+	if overflow {
+		panic(lox.NewRuntimeError(operator, "Integer overflow."))
+	}
+	return c
+}
+
+// divide / 整数和浮点数为浮点除法, 大整数和十进制小数得到精确的十进制小数
+func divide(operator *token.Token, left, right interface{}) interface{} {
+	switch checkNumbers(operator, left, right) {
+	case kindBig, kindDecimal:
+		b := toRat(right)
+		if b.Sign() == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		return new(big.Rat).Quo(toRat(left), b)
+	}
+	return toFloat(left) / toFloat(right)
+}
+
+// intDivide ~/ 整数除法, 向零取整; 大整数和十进制小数得到大整数
+func intDivide(operator *token.Token, left, right interface{}) interface{} {
+	switch checkNumbers(operator, left, right) {
+	case kindInt:
+		a, b := left.(int64), right.(int64)
+		if b == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		if a == math.MinInt64 && b == -1 {
+			panic(lox.NewRuntimeError(operator, "Integer overflow."))
+		}
+		return a / b
+	case kindBig, kindDecimal:
+		b := toRat(right)
+		if b.Sign() == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		q := new(big.Rat).Quo(toRat(left), b)
+		return new(big.Int).Quo(q.Num(), q.Denom())
+	}
+
+	a, b := toFloat(left), toFloat(right)
+	if b == 0 {
+		panic(lox.NewRuntimeError(operator, "Division by zero."))
+	}
+	q := math.Trunc(a / b)
+	if math.IsNaN(q) || q < math.MinInt64 || q >= math.MaxInt64 {
+		panic(lox.NewRuntimeError(operator, "Integer overflow."))
+	}
+	return int64(q)
+}
+
+// compare 比较两个数字, 返回 -1, 0 或 1; 有 NaN 时返回 2, 所有比较都为 false
+func compare(operator *token.Token, left, right interface{}) int {
+	k1, ok := kindOf(left)
+	k2, ok1 := kindOf(right)
+	if !ok || !ok1 {
+		panic(lox.NewRuntimeError(operator, "Operands must be numbers."))
+	}
+	return compareNumbers(k1, k2, left, right)
+}
+
+func compareNumbers(k1, k2 numKind, left, right interface{}) int {
+	if k1 == kindInt && k2 == kindInt {
+		a, b := left.(int64), right.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	if k1 == kindFloat || k2 == kindFloat {
+		a, b := toFloat(left), toFloat(right)
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return 2
+		}
+		if math.IsInf(a, 0) || math.IsInf(b, 0) || (exactFloat(left) && exactFloat(right)) {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	// 浮点数可以精确地转换为有理数, 这样 0.1 != 0.1m
+	return exactRat(left).Cmp(exactRat(right))
+}
+
+// exactFloat v 可以精确地表示为 float64
+func exactFloat(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return true
+	case int64:
+		return n >= -1<<53 && n <= 1<<53
+	}
+	return false
+}
+
+func exactRat(v interface{}) *big.Rat {
+	if f, ok := v.(float64); ok {
+		return new(big.Rat).SetFloat64(f)
+	}
+	return toRat(v)
+}
+
+func negate(operator *token.Token, v interface{}) interface{} {
+	switch n := v.(type) {
+	case int64:
+		if n == math.MinInt64 {
+			panic(lox.NewRuntimeError(operator, "Integer overflow."))
+		}
+		return -n
+	case *big.Int:
+		return new(big.Int).Neg(n)
+	case *big.Rat:
+		return new(big.Rat).Neg(n)
+	case float64:
+		return -n
+	}
+	panic(lox.NewRuntimeError(operator, "Operand must be a number."))
+}
+
+// numbersEqual 不同种类的数字按数值比较, 1 == 1.0 == 1n == 1m
+func numbersEqual(left, right interface{}) bool {
+	k1, _ := kindOf(left)
+	k2, _ := kindOf(right)
+	return compareNumbers(k1, k2, left, right) == 0
+}
+
+// formatFloat 最短的能够精确还原的形式, 很大或很小的数使用指数形式
+func formatFloat(v float64) string {
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatDecimal 有限小数精确输出, 无限小数保留 DecimalPlaces 位并去掉末尾的 0
+func formatDecimal(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// 分母只有因子 2 和 5 时是有限小数, 位数为两个因子个数的较大者
+	d := new(big.Int).Set(r.Denom())
+	places := 0
+	for _, f := range []int64{2, 5} {
+		factor, n, m := big.NewInt(f), 0, new(big.Int)
+		for {
+			q, rem := new(big.Int).QuoRem(d, factor, m)
+			if rem.Sign() != 0 {
+				break
+			}
+			d = q
+			n++
+		}
+		if n > places {
+			places = n
+		}
+	}
+	if d.Cmp(big.NewInt(1)) == 0 {
+		return r.FloatString(places)
+	}
+
+	s := r.FloatString(DecimalPlaces)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// modulo % 余数的符号与被除数相同, 与 ~/ 向零取整一致: a == (a ~/ b) * b + a % b
+func modulo(operator *token.Token, left, right interface{}) interface{} {
+	switch checkNumbers(operator, left, right) {
+	case kindInt:
+		a, b := left.(int64), right.(int64)
+		if b == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		if b == -1 {
+			return int64(0)
+		}
+		return a % b
+	case kindBig:
+		b := toBig(right)
+		if b.Sign() == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		return new(big.Int).Rem(toBig(left), b)
+	case kindDecimal:
+		a, b := toRat(left), toRat(right)
+		if b.Sign() == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		q := new(big.Rat).Quo(a, b)
+		trunc := new(big.Rat).SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+		return new(big.Rat).Sub(a, trunc.Mul(trunc, b))
+	}
+
+	b := toFloat(right)
+	if b == 0 {
+		panic(lox.NewRuntimeError(operator, "Division by zero."))
+	}
+	return math.Mod(toFloat(left), b)
+}
+
+// MaxBigExponent 大整数和十进制小数的幂运算允许的最大指数, 避免一次运算耗尽内存
+const MaxBigExponent = 1 << 16
+
+// power ** 整数的非负整数次幂是精确的, 负指数或者有浮点数时使用 math.Pow
+func power(operator *token.Token, left, right interface{}) interface{} {
+	kind := checkNumbers(operator, left, right)
+	if kind == kindFloat {
+		return math.Pow(toFloat(left), toFloat(right))
+	}
+
+	exp, ok := integerValue(right)
+	if !ok {
+		if kind == kindDecimal {
+			panic(lox.NewRuntimeError(operator, "Decimal exponent must be an integer."))
+		}
+		return math.Pow(toFloat(left), toFloat(right))
+	}
+
+	switch kind {
+	case kindInt:
+		if exp < 0 {
+			return math.Pow(toFloat(left), float64(exp))
+		}
+		base, result := left.(int64), int64(1)
+		switch {
+		case exp == 0 || base == 1:
+			return int64(1)
+		case base == 0:
+			return int64(0)
+		case base == -1:
+			return 1 - 2*(exp%2)
+		}
+		// |base| >= 2 时最多 63 次就会溢出
+		for ; exp > 0; exp-- {
+			next := result * base
+			if base != 0 && (next/base != result || (base == -1 && result == math.MinInt64)) {
+				panic(lox.NewRuntimeError(operator, "Integer overflow."))
+			}
+			result = next
+		}
+		return result
+	case kindBig:
+		if exp < 0 {
+			return power(operator, toRat(left), right)
+		}
+		checkBigExponent(operator, exp)
+		return new(big.Int).Exp(toBig(left), big.NewInt(exp), nil)
+	}
+
+	// kindDecimal
+	base := toRat(left)
+	if exp < 0 {
+		if base.Sign() == 0 {
+			panic(lox.NewRuntimeError(operator, "Division by zero."))
+		}
+		base, exp = new(big.Rat).Inv(base), -exp
+	}
+	checkBigExponent(operator, exp)
+	e := big.NewInt(exp)
+	num := new(big.Int).Exp(base.Num(), e, nil)
+	denom := new(big.Int).Exp(base.Denom(), e, nil)
+	return new(big.Rat).SetFrac(num, denom)
+}
+
+func checkBigExponent(operator *token.Token, exp int64) {
+	if exp > MaxBigExponent {
+		panic(lox.NewRuntimeError(operator, "Exponent too large."))
+	}
+}
+
+// integerValue 整数值的数字转换为 int64
+func integerValue(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case *big.Int:
+		if n.IsInt64() {
+			return n.Int64(), true
+		}
+	case *big.Rat:
+		if n.IsInt() && n.Num().IsInt64() {
+			return n.Num().Int64(), true
+		}
+	}
+	return 0, false
+}
+
+// checkIntegers 位运算的操作数必须是 int64 或 *big.Int, 有大整数时返回 true
+func checkIntegers(operator *token.Token, left, right interface{}) (isBig bool) {
+	k1, ok := kindOf(left)
+	k2, ok1 := kindOf(right)
+	if !ok || !ok1 || k1 > kindBig || k2 > kindBig {
+		panic(lox.NewRuntimeError(operator, "Operands must be integers."))
+	}
+	return k1 == kindBig || k2 == kindBig
+}
+
+// bitwise & | ^
+func bitwise(operator *token.Token, left, right interface{}) interface{} {
+	if checkIntegers(operator, left, right) {
+		a, b := toBig(left), toBig(right)
+		switch operator.TokenType {
+		case token.Ampersand:
+			return new(big.Int).And(a, b)
+		case token.Pipe:
+			return new(big.Int).Or(a, b)
+		default:
+			return new(big.Int).Xor(a, b)
+		}
+	}
+
+	a, b := left.(int64), right.(int64)
+	switch operator.TokenType {
+	case token.Ampersand:
+		return a & b
+	case token.Pipe:
+		return a | b
+	default:
+		return a ^ b
+	}
+}
+
+// shift << >>, >> 是算术右移; int64 左移溢出时报错
+func shift(operator *token.Token, left, right interface{}) interface{} {
+	isBig := checkIntegers(operator, left, right)
+	n, ok := integerValue(right)
+	if !ok || n < 0 {
+		panic(lox.NewRuntimeError(operator, "Shift count must be a non-negative integer."))
+	}
+
+	if isBig {
+		if operator.TokenType == token.GreaterGreater {
+			return new(big.Int).Rsh(toBig(left), uint(n))
+		}
+		checkBigExponent(operator, n)
+		return new(big.Int).Lsh(toBig(left), uint(n))
+	}
+
+	a := left.(int64)
+	if operator.TokenType == token.GreaterGreater {
+		if n >= 64 {
+			n = 63
+		}
+		return a >> uint(n)
+	}
+	if a == 0 {
+		return a
+	}
+	if n >= 64 || (a<<uint(n))>>uint(n) != a {
+		panic(lox.NewRuntimeError(operator, "Integer overflow."))
+	}
+	return a << uint(n)
+}
+
+func bitNot(operator *token.Token, v interface{}) interface{} {
+	switch n := v.(type) {
+	case int64:
+		return ^n
+	case *big.Int:
+		return new(big.Int).Not(n)
+	}
+	panic(lox.NewRuntimeError(operator, "Operand must be an integer."))
+}
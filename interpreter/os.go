@@ -0,0 +1,140 @@
+package interpreter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Exit os.exit(code) panic 的值, 像运行时错误一样展开调用栈并结束所有任务, 最后由 Run 返回
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// osModule getenv 和 setenv 还需要 env 能力, exec 还需要 process 能力
+func osModule(cfg *Config) *Module {
+	members := map[string]interface{}{
+		"platform": runtime.GOOS,
+		"args":     stringList(append([]string{}, cfg.Args...)),
+
+		// exit(code) code 省略时为 0
+		"exit": NewNativeFunction("exit", -1, func(itp *Interpreter, args []interface{}) interface{} {
+			checkArgCount(args, 0, 1)
+			code := int64(0)
+			if len(args) == 1 {
+				n, ok := integerValue(args[0])
+				if !ok || n < 0 || n > 255 {
+					panic(lox.NewRuntimeError(nil, "exit() code must be an integer from 0 to 255."))
+				}
+				code = n
+			}
+			panic(&Exit{Code: int(code)})
+		}),
+		// cwd 和 chdir 是解释器自己的工作目录, 不改变宿主进程的; fs, path.abs 和 exec 都相对于它
+		"cwd": NewNativeFunction("cwd", 0, func(itp *Interpreter, args []interface{}) interface{} {
+			return itp.proc.cwd()
+		}),
+		// chdir 与 fs 一样只能进入 cfg.Paths 中的目录, 失败时返回 *Error
+		"chdir": NewNativeFunction("chdir", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			path := stringArg("chdir", args[0])
+			dir := cfg.checkPath(itp, path)
+			info, err := os.Stat(dir)
+			if err != nil {
+				return fsError("change to", path, err)
+			}
+			if !info.IsDir() {
+				return NewError("Cannot change to '" + path + "': not a directory.")
+			}
+			itp.proc.chdir(dir)
+			return nil
+		}),
+	}
+
+	if cfg.Granted(CapEnv) {
+		members["getenv"] = NewNativeFunction("getenv", 1, getenv("getenv"))
+		members["setenv"] = NewNativeFunction("setenv", 2, setenv("setenv"))
+	} else {
+		members["getenv"] = &denied{capability: CapEnv}
+		members["setenv"] = &denied{capability: CapEnv}
+	}
+	// exec(cmd, args) 直接运行 cmd 而不经过 shell, 等待结束后返回 stdout, stderr 和 code
+	if cfg.Granted(CapProcess) {
+		members["exec"] = NewNativeFunction("exec", -1, osExec)
+	} else {
+		members["exec"] = &denied{capability: CapProcess}
+	}
+	return NewModule("os", members)
+}
+
+func osExec(itp *Interpreter, args []interface{}) interface{} {
+	checkArgCount(args, 1, 2)
+	name := stringArg("exec", args[0])
+
+	var argv []string
+	if len(args) == 2 {
+		list, ok := args[1].(*List)
+		if !ok {
+			panic(lox.NewRuntimeError(nil, "exec() arguments must be a list of strings."))
+		}
+		for _, e := range list.Elements {
+			s, ok := e.(string)
+			if !ok {
+				panic(lox.NewRuntimeError(nil, "exec() arguments must be a list of strings."))
+			}
+			argv = append(argv, s)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, argv...)
+	cmd.Dir = itp.proc.cwd()
+	cmd.Env = itp.proc.environ()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// 非 0 的退出码不是错误, 由脚本检查 code
+	err := cmd.Run()
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		if e, ok := err.(*exec.Error); ok {
+			err = e.Err
+		}
+		panic(lox.NewRuntimeError(nil, "Cannot run '"+name+"': "+err.Error()+"."))
+	}
+	return &ExecResult{name: name, Stdout: stdout.String(), Stderr: stderr.String(), Code: int64(code)}
+}
+
+// ExecResult os.exec() 的结果, 属性有 stdout, stderr 和 code
+type ExecResult struct {
+	name   string
+	Stdout string
+	Stderr string
+	Code   int64
+}
+
+func (r *ExecResult) Get(name *token.Token) interface{} {
+	switch name.Lexeme {
+	case "stdout":
+		return r.Stdout
+	case "stderr":
+		return r.Stderr
+	case "code":
+		return r.Code
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+func (r *ExecResult) String() string {
+	return "<exec " + r.name + ">"
+}
@@ -0,0 +1,87 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+)
+
+// TestProcessState 共享 NewGlobals 的解释器各自有工作目录和环境变量, 都不会修改宿主进程
+func TestProcessState(t *testing.T) {
+	dir := sandbox(t)
+	chdir(t, dir)
+	if err := os.Mkdir(filepath.Join(dir, "allowed", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &interpreter.Config{
+		Capabilities: []interpreter.Capability{interpreter.CapOS, interpreter.CapEnv, interpreter.CapFS, interpreter.CapProcess},
+		Paths:        []string{"allowed"},
+	}
+
+	reporter := lox.NewReporter(&bytes.Buffer{})
+	globals, err := interpreter.NewGlobals(nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, n)
+	results := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			itp := interpreter.NewInterpreterWithGlobals(globals)
+			itp.SetOutput(&outputs[i])
+			// 偶数编号的解释器进入 allowed/sub 并设置 LOX_PROCESS_TEST, 奇数编号的只读取
+			source := `
+print os.getenv("LOX_PROCESS_TEST");
+print path.base(os.cwd());
+var r = os.exec("sh", ["-c", "echo \"env=$LOX_PROCESS_TEST dir=$(basename \"$PWD\")\""]);
+print r.stdout.trim();
+`
+			if i%2 == 0 {
+				source = fmt.Sprintf(`
+os.chdir("allowed/sub");
+os.setenv("LOX_PROCESS_TEST", "%d");
+env.set("LOX_PROCESS_OTHER", "x");
+`, i) + source + `print fs.exists("../data.txt");`
+			}
+			results[i] = itp.Run(parse(reporter, source))
+		}(i)
+	}
+	wg.Wait()
+
+	base := filepath.Base(dir)
+	for i := 0; i < n; i++ {
+		want := fmt.Sprintf("nil\n%s\nenv= dir=%s\n", base, base)
+		if i%2 == 0 {
+			want = fmt.Sprintf("%d\nsub\nenv=%d dir=sub\ntrue\n", i, i)
+		}
+		if results[i] != nil || outputs[i].String() != want {
+			t.Errorf("interpreter %d: got output %q and error %v, want %q", i, outputs[i].String(), results[i], want)
+		}
+	}
+
+	if _, ok := os.LookupEnv("LOX_PROCESS_TEST"); ok {
+		t.Error("host environment changed")
+	}
+	if wd, _ := os.Getwd(); wd != dir {
+		t.Errorf("host working directory changed to %s", wd)
+	}
+}
+
+func TestSetenvInvalidName(t *testing.T) {
+	_, err := runWith(t, interpreter.FullAccess(), `os.setenv("A=B", "1");`)
+	if want := "Invalid environment variable 'A=B'."; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,33 @@
+package interpreter
+
+import (
+	"path/filepath"
+)
+
+// pathModule 只处理路径字符串, 不访问文件, 总是安装为全局变量 path
+func pathModule() *Module {
+	return NewModule("path", map[string]interface{}{
+		// join 可以有任意个参数
+		"join": NewNativeFunction("join", -1, func(itp *Interpreter, args []interface{}) interface{} {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = stringArg("join", arg)
+			}
+			return filepath.Join(parts...)
+		}),
+		"base": NewNativeFunction("base", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return filepath.Base(stringArg("base", args[0]))
+		}),
+		"dir": NewNativeFunction("dir", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return filepath.Dir(stringArg("dir", args[0]))
+		}),
+		// ext 包含 ".", 没有扩展名时为 ""
+		"ext": NewNativeFunction("ext", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return filepath.Ext(stringArg("ext", args[0]))
+		}),
+		// abs 相对于解释器的工作目录, 见 os.chdir
+		"abs": NewNativeFunction("abs", 1, func(itp *Interpreter, args []interface{}) interface{} {
+			return itp.proc.abs(stringArg("abs", args[0]))
+		}),
+	})
+}
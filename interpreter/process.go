@@ -0,0 +1,95 @@
+package interpreter
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/lox"
+)
+
+// process 解释器自己的工作目录和环境变量
+//
+// os.chdir, os.setenv 和 env.set 只修改它而不修改宿主进程, 所以共享 NewGlobals 的解释器互不影响;
+// spawn 出的任务和生成器与创建它们的解释器共享同一个 process, 所以由 mu 保护
+type process struct {
+	mu  sync.Mutex
+	dir string            // 创建解释器时宿主进程的工作目录
+	env map[string]string // 第一次设置环境变量之前为 nil, 表示与宿主进程相同
+}
+
+func newProcess() *process {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = string(filepath.Separator)
+	}
+	return &process{dir: dir}
+}
+
+func (p *process) cwd() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dir
+}
+
+func (p *process) chdir(dir string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dir = dir
+}
+
+// abs 相对于工作目录的绝对路径
+func (p *process) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(p.cwd(), path)
+}
+
+func (p *process) getenv(name string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.env == nil {
+		return os.LookupEnv(name)
+	}
+	v, ok := p.env[name]
+	return v, ok
+}
+
+func (p *process) setenv(name, value string) {
+	if name == "" || strings.ContainsAny(name, "=\x00") || strings.ContainsRune(value, 0) {
+		panic(lox.NewRuntimeError(nil, "Invalid environment variable '"+name+"'."))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.env == nil {
+		p.env = make(map[string]string)
+		for _, kv := range os.Environ() {
+			if i := strings.Index(kv, "="); i > 0 {
+				p.env[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+	p.env[name] = value
+}
+
+// environ os.exec 启动的命令的环境变量, nil 表示继承宿主进程的
+func (p *process) environ() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.env == nil {
+		return nil
+	}
+	env := make([]string, 0, len(p.env))
+	for k, v := range p.env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,203 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// maxStringLength repeat 和 replace 产生的字符串最多的字节数
+const maxStringLength = 1 << 28
+
+// maxPrecision toString 最多保留的小数位数
+const maxPrecision = 100
+
+// stringMethod s.name 绑定到 s 的方法, 下标和长度都按 rune 计算
+func stringMethod(name *token.Token, s string) interface{} {
+	method := func(arity int, fn func(itp *Interpreter, args []interface{}) interface{}) *NativeFunction {
+		return NewNativeFunction(name.Lexeme, arity, fn)
+	}
+
+	switch name.Lexeme {
+	case "len":
+		return method(0, func(itp *Interpreter, args []interface{}) interface{} {
+			return int64(utf8.RuneCountInString(s))
+		})
+	case "substring":
+		// substring(start, end) 包含 start 不包含 end, 省略 end 时到字符串结尾
+		return method(-1, func(itp *Interpreter, args []interface{}) interface{} {
+			checkArgCount(args, 1, 2)
+			runes := []rune(s)
+			start, end := indexArg("substring", args[0]), int64(len(runes))
+			if len(args) == 2 {
+				end = indexArg("substring", args[1])
+			}
+			if start < 0 || end > int64(len(runes)) || start > end {
+				panic(lox.NewRuntimeError(nil, "substring() index out of range."))
+			}
+			return string(runes[start:end])
+		})
+	case "indexOf":
+		// indexOf 没有找到时返回 -1
+		return method(1, func(itp *Interpreter, args []interface{}) interface{} {
+			i := strings.Index(s, stringArg("indexOf", args[0]))
+			if i < 0 {
+				return int64(-1)
+			}
+			return int64(utf8.RuneCountInString(s[:i]))
+		})
+	case "split":
+		// split("") 拆分为单个字符
+		return method(1, func(itp *Interpreter, args []interface{}) interface{} {
+			return stringList(strings.Split(s, stringArg("split", args[0])))
+		})
+	case "join":
+		// ", ".join(list) 用 s 连接列表中各个值的字符串表示
+		return method(1, func(itp *Interpreter, args []interface{}) interface{} {
+			list, ok := args[0].(*List)
+			if !ok {
+				panic(lox.NewRuntimeError(nil, "join() argument must be a list."))
+			}
+			parts := make([]string, len(list.Elements))
+			for i, e := range list.Elements {
+				parts[i] = itp.stringify(e)
+			}
+			return strings.Join(parts, s)
+		})
+	case "trim":
+		return method(0, func(itp *Interpreter, args []interface{}) interface{} {
+			return strings.TrimSpace(s)
+		})
+	case "upper":
+		return method(0, func(itp *Interpreter, args []interface{}) interface{} {
+			return strings.ToUpper(s)
+		})
+	case "lower":
+		return method(0, func(itp *Interpreter, args []interface{}) interface{} {
+			return strings.ToLower(s)
+		})
+	case "replace":
+		// replace 替换所有出现的 old
+		return method(2, func(itp *Interpreter, args []interface{}) interface{} {
+			old, new := stringArg("replace", args[0]), stringArg("replace", args[1])
+			if old == "" && len(s)+(len(s)+1)*len(new) > maxStringLength {
+				panic(lox.NewRuntimeError(nil, "replace() result is too large."))
+			}
+			return strings.Replace(s, old, new, -1)
+		})
+	case "startsWith":
+		return method(1, func(itp *Interpreter, args []interface{}) interface{} {
+			return strings.HasPrefix(s, stringArg("startsWith", args[0]))
+		})
+	case "endsWith":
+		return method(1, func(itp *Interpreter, args []interface{}) interface{} {
+			return strings.HasSuffix(s, stringArg("endsWith", args[0]))
+		})
+	case "repeat":
+		return method(1, func(itp *Interpreter, args []interface{}) interface{} {
+			n, ok := integerValue(args[0])
+			if !ok || n < 0 {
+				panic(lox.NewRuntimeError(nil, "repeat() count must be a non-negative integer."))
+			}
+			if len(s) > 0 && n > maxStringLength/int64(len(s)) {
+				panic(lox.NewRuntimeError(nil, "repeat() result is too large."))
+			}
+			return strings.Repeat(s, int(n))
+		})
+	case "chars":
+		return method(0, func(itp *Interpreter, args []interface{}) interface{} {
+			runes := []rune(s)
+			chars := make([]string, len(runes))
+			for i, r := range runes {
+				chars[i] = string(r)
+			}
+			return stringList(chars)
+		})
+	case "parseNumber":
+		return method(0, func(itp *Interpreter, args []interface{}) interface{} {
+			return parseNumber(s)
+		})
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+// numberMethod 数字的方法, 只有 toString
+func numberMethod(name *token.Token, n interface{}) interface{} {
+	if name.Lexeme == "toString" {
+		// toString(precision) 保留 precision 位小数, 省略时与 print 一致
+		return NewNativeFunction("toString", -1, func(itp *Interpreter, args []interface{}) interface{} {
+			checkArgCount(args, 0, 1)
+			if len(args) == 0 {
+				return itp.stringify(n)
+			}
+			p, ok := integerValue(args[0])
+			if !ok || p < 0 || p > maxPrecision {
+				panic(lox.NewRuntimeError(nil, fmt.Sprintf("toString() precision must be an integer from 0 to %d.", maxPrecision)))
+			}
+			return formatPrecision(n, int(p))
+		})
+	}
+	panic(lox.NewRuntimeError(name, "Undefined property '"+name.Lexeme+"'."))
+}
+
+// formatPrecision 整数和十进制小数精确地舍入, 浮点数按照它的二进制值舍入
+func formatPrecision(n interface{}, p int) string {
+	if f, ok := n.(float64); ok {
+		return strconv.FormatFloat(f, 'f', p, 64)
+	}
+	return toRat(n).FloatString(p)
+}
+
+// parseNumber 按照数字字面量的语法解析 s, 可以有前导的 -, 不是数字时返回 nil
+func parseNumber(s string) interface{} {
+	failed := false
+	sc := scanner.NewScanner(strings.TrimSpace(s))
+	sc.SetErrFunc(func(line, column int, msg string) { failed = true })
+	tokens := sc.ScanTokens()
+
+	negative := len(tokens) == 3 && tokens[0].TokenType == token.Minus
+	if negative {
+		tokens = tokens[1:]
+	}
+	if failed || len(tokens) != 2 || tokens[0].TokenType != token.Number {
+		return nil
+	}
+	if _, ok := tokens[0].Literal.(scanner.MinInt64Magnitude); ok {
+		if negative {
+			return int64(math.MinInt64)
+		}
+		return nil
+	}
+	if negative {
+		return negate(tokens[0], tokens[0].Literal)
+	}
+	return tokens[0].Literal
+}
+
+func checkArgCount(args []interface{}, min, max int) {
+	if len(args) < min || len(args) > max {
+		panic(lox.NewRuntimeError(nil, fmt.Sprintf("Expected %d to %d arguments but got %d.", min, max, len(args))))
+	}
+}
+
+func indexArg(fn string, v interface{}) int64 {
+	n, ok := integerValue(v)
+	if !ok {
+		panic(lox.NewRuntimeError(nil, fn+"() arguments must be integers."))
+	}
+	return n
+}
+
+func stringList(parts []string) *List {
+	elements := make([]interface{}, len(parts))
+	for i, p := range parts {
+		elements[i] = p
+	}
+	return NewList(elements)
+}
@@ -0,0 +1,212 @@
+package interpreter
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// group 一个脚本和它 spawn 出的所有任务共享的状态
+//
+// running 为没有阻塞在通道操作上的任务个数 (包括脚本本身), 降到 0 而还有任务在等待时就是死锁.
+// 一个任务失败后, 所有阻塞中和之后阻塞的任务都以同样的错误失败
+type group struct {
+	// mu 保护组的调度状态和组中创建的 Channel; 脚本出错后替换它的组沿用同一个锁,
+	// 不同的解释器使用不同的锁, 互不影响
+	mu *sync.Mutex
+
+	running int
+	tasks   int // 还没有结束的 spawn 出的任务个数
+	waiters map[*waiter]bool
+	joiner  *waiter     // 等待所有任务结束的脚本
+	err     interface{} // 第一个失败的任务的 panic 值
+
+	abandoned int32      // 脚本出错后置为 1, 还在运行的 spawn 出的任务在下一条语句前结束, 原子操作
+	steps     int64      // 所有任务执行过的语句数, 原子操作
+	out       sync.Mutex // 串行化 print
+}
+
+// Task 一个并发执行的任务: 脚本和 spawn 出的每个任务各有一个, 生成器属于创建它的任务
+type Task struct {
+	spawned bool
+}
+
+// Spawned 是否是 spawn 出的任务, 它调用的函数返回后任务就结束了
+func (t *Task) Spawned() bool {
+	return t.spawned
+}
+
+// Task 正在执行的任务, 钩子可以用它区分并发调用的任务
+func (itp *Interpreter) Task() *Task {
+	return itp.task
+}
+
+func newGroup(mu *sync.Mutex) *group {
+	return &group{mu: mu, running: 1, waiters: make(map[*waiter]bool)}
+}
+
+// waiter 阻塞在一组通道操作上的任务, 其中一个操作被另一个任务完成, 或者出错时被唤醒
+type waiter struct {
+	group *group
+	tk    *token.Token
+	ops   []chanOp
+	fired bool
+	index int
+	value interface{}
+	err   interface{}
+	wake  chan struct{}
+}
+
+func newWaiter(g *group, tk *token.Token, ops []chanOp) *waiter {
+	return &waiter{group: g, tk: tk, ops: ops, wake: make(chan struct{}, 1)}
+}
+
+// complete 第 index 个操作已经完成, value 为收到的值; 调用时持有 group.mu
+func (w *waiter) complete(index int, value interface{}) {
+	w.fired, w.index, w.value = true, index, value
+	w.group.unblock(w)
+}
+
+// fail 以 err 唤醒 w; 调用时持有 group.mu
+func (w *waiter) fail(err interface{}) {
+	// 同一个错误会在多个 goroutine 中 panic, 复制一份以免补全位置时互相影响
+	if e, ok := err.(*lox.RuntimeError); ok {
+		copied := *e
+		err = &copied
+	}
+	w.fired, w.err = true, err
+	w.group.unblock(w)
+}
+
+func (g *group) block(w *waiter) {
+	g.running--
+	g.waiters[w] = true
+	g.checkDeadlock()
+}
+
+func (g *group) unblock(w *waiter) {
+	g.running++
+	delete(g.waiters, w)
+	w.wake <- struct{}{}
+}
+
+func (g *group) checkDeadlock() {
+	if g.running > 0 {
+		return
+	}
+	for w := range g.waiters {
+		w.fail(lox.NewRuntimeError(w.tk, "Deadlock: all tasks are blocked."))
+	}
+}
+
+// fail 记录第一个错误并唤醒所有阻塞的任务; 调用时持有 mu
+func (g *group) fail(err interface{}) {
+	if g.err == nil {
+		g.err = err
+	}
+	for w := range g.waiters {
+		w.fail(err)
+	}
+}
+
+// wait 阻塞直到 w 被唤醒, 出错时在当前 goroutine 中 panic
+func (w *waiter) wait() {
+	<-w.wake
+	if w.err != nil {
+		panic(w.err)
+	}
+}
+
+func (itp *Interpreter) VisitorSpawnStmtExpr(stmt *expr.Spawn) interface{} {
+	callee := itp.evaluate(stmt.Call.Callee)
+	arguments := itp.arguments(stmt.Call.Arguments)
+	function := itp.callable(stmt.Call.Paren, callee, arguments)
+
+	task := itp.fork(itp.globals)
+	task.frames = []*Frame{{Name: "<spawn>", Env: itp.globals}}
+	task.task = &Task{spawned: true}
+
+	g := itp.group
+	g.mu.Lock()
+	g.running++
+	g.tasks++
+	g.mu.Unlock()
+
+	go func() {
+		var failure interface{}
+		defer func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+
+			g.running--
+			g.tasks--
+			if failure != nil {
+				g.fail(failure)
+			}
+			if g.tasks == 0 && g.joiner != nil {
+				g.joiner.complete(-1, nil)
+				g.joiner = nil
+			}
+			g.checkDeadlock()
+		}()
+		defer func() {
+			failure = recover()
+		}()
+
+		task.call(stmt.Call.Paren, function, arguments)
+	}()
+	return nil
+}
+
+// join 等待所有 spawn 出的任务结束, 有任务失败时 panic 第一个任务的错误
+func (itp *Interpreter) join() {
+	w := itp.startJoin()
+	if w != nil {
+		w.wait()
+	}
+}
+
+func (itp *Interpreter) startJoin() *waiter {
+	g := itp.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		panic(g.err)
+	}
+	if g.tasks == 0 {
+		return nil
+	}
+
+	w := newWaiter(g, nil, nil)
+	g.joiner = w
+	g.block(w)
+	return w
+}
+
+// abandon 脚本出错时结束它的任务组, 之后的任务在新的组中运行
+func (itp *Interpreter) abandon(err interface{}) {
+	g := itp.group
+	g.mu.Lock()
+	g.fail(err)
+	g.mu.Unlock()
+	atomic.StoreInt32(&g.abandoned, 1)
+
+	itp.group = newGroup(g.mu)
+}
+
+// checkAbandoned 任务组被放弃后, spawn 出的任务以组的错误结束
+func (itp *Interpreter) checkAbandoned() {
+	g := itp.group
+	if !itp.task.spawned || atomic.LoadInt32(&g.abandoned) == 0 {
+		return
+	}
+
+	g.mu.Lock()
+	err := g.err
+	g.mu.Unlock()
+	panic(err)
+}
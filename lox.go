@@ -2,20 +2,61 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/zhiruchen/lox-go/conformance"
+	"github.com/zhiruchen/lox-go/coverage"
+	"github.com/zhiruchen/lox-go/dap"
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/fuzz"
 	"github.com/zhiruchen/lox-go/interpreter"
 	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/lsp"
 	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/profile"
 	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/testrunner"
 )
 
+var (
+	traceFlag    = flag.Bool("trace", false, "log each executed statement to stderr")
+	profileFlag  = flag.String("profile", "", "write a pprof profile to `file` and a report to stderr")
+	coverageFlag = flag.String("coverage", "", "merge coverage into the LCOV `file` and write an HTML view next to it")
+	allowFlag    = flag.String("allow", "", "grant only these comma-separated `capabilities` (fs, os, time, net-local, env, process) instead of all; process lets os.exec run any program, bypassing --allow-path")
+	pathFlag     = flag.String("allow-path", "", "comma-separated `paths` the fs module and os.chdir may access when --allow is set")
+)
+
+// config 没有 --allow 时授予所有能力, args 为脚本的 os.args
+func config(args []string) *interpreter.Config {
+	if *allowFlag == "" {
+		cfg := interpreter.FullAccess()
+		cfg.Args = args
+		return cfg
+	}
+
+	var paths []string
+	if *pathFlag != "" {
+		paths = strings.Split(*pathFlag, ",")
+	}
+	cfg, err := interpreter.NewConfig(strings.Split(*allowFlag, ","), paths)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cfg.Args = args
+	return cfg
+}
+
 func runPrompt() {
 
 	reader := bufio.NewReader(os.Stdin)
-	itp := interpreter.NewInterpreter()
+	itp := interpreter.NewInterpreterWithConfig(config(nil))
 
 	for {
 		fmt.Print("code > ")
@@ -28,13 +69,274 @@ func runPrompt() {
 	}
 }
 
+// runFile args 为脚本路径之后的命令行参数
+func runFile(path string, args []string) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	itp := interpreter.NewInterpreterWithConfig(config(args))
+	statements, ok := parse(itp.Reporter(), string(source))
+	if !ok {
+		os.Exit(lox.ExitSyntaxError)
+	}
+
+	var hooks []interpreter.Hook
+	if *traceFlag {
+		hooks = append(hooks, profile.NewTracer(os.Stderr))
+	}
+	var profiler *profile.Profiler
+	if *profileFlag != "" {
+		profiler = profile.NewProfiler()
+		hooks = append(hooks, profiler)
+	}
+	var recorder *coverage.Recorder
+	if *coverageFlag != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		recorder = coverage.NewRecorder(abs, statements)
+		hooks = append(hooks, recorder)
+	}
+	if len(hooks) > 0 {
+		itp.SetHook(interpreter.MultiHook(hooks...))
+	}
+
+	runErr := itp.Run(statements)
+	exit, exited := runErr.(*interpreter.Exit)
+	if runErr != nil && !exited {
+		itp.Reporter().RuntimeError(runErr)
+	}
+
+	if profiler != nil {
+		profiler.Stop()
+		writeProfile(profiler, *profileFlag, path)
+	}
+	if recorder != nil {
+		writeCoverage(recorder.Report(), *coverageFlag)
+	}
+	if exited {
+		os.Exit(exit.Code)
+	}
+	if runErr != nil {
+		os.Exit(lox.ExitRuntimeError)
+	}
+}
+
+// writeCoverage 与 out 中已有的结果合并后写出 LCOV 和 HTML
+func writeCoverage(report *coverage.Report, out string) {
+	if f, err := os.Open(out); err == nil {
+		previous, err := coverage.ReadLCOV(f)
+		f.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		previous.Merge(report)
+		report = previous
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+	if err := report.WriteLCOV(f); err != nil {
+		log.Fatalln(err)
+	}
+
+	h, err := os.Create(strings.TrimSuffix(out, filepath.Ext(out)) + ".html")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer h.Close()
+	if err := report.WriteHTML(h); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func writeProfile(profiler *profile.Profiler, out, script string) {
+	f, err := os.Create(out)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+
+	if err := profiler.WritePprof(f, script); err != nil {
+		log.Fatalln(err)
+	}
+	if err := profiler.WriteReport(os.Stderr); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run REPL 中的一行, 每一行重新开始记录错误
 func run(itp *interpreter.Interpreter, source string) {
+	itp.Reporter().Reset()
+	statements, ok := parse(itp.Reporter(), source)
+	if !ok {
+		return
+	}
+	err := itp.Run(statements)
+	if exit, ok := err.(*interpreter.Exit); ok {
+		os.Exit(exit.Code)
+	}
+	if err != nil {
+		itp.Reporter().RuntimeError(err)
+	}
+}
+
+func parse(reporter *lox.Reporter, source string) ([]expr.Stmt, bool) {
 	s := scanner.NewScanner(source)
+	s.SetErrFunc(reporter.ScanError)
 	tokens := s.ScanTokens()
-	p := parser.NewParser(tokens, lox.TokenError)
-	itp.Interpret(p.Parse())
+	p := parser.NewParser(tokens, reporter.TokenError)
+	p.SetWarnFunc(reporter.Warning)
+	statements := p.Parse()
+	return statements, !reporter.HadError
+}
+
+// runLSP language server, 通过 stdin/stdout 通信; 补全的内置模块与 --allow 一致
+func runLSP() {
+	if err := lsp.NewServerWithConfig(os.Stdin, os.Stdout, config(nil)).Serve(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// runDAP debug adapter, 通过 stdin/stdout 通信; launch 参数中没有 allow 时使用 --allow 的能力
+func runDAP() {
+	if err := dap.NewServerWithConfig(os.Stdin, os.Stdout, config(nil)).Serve(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// runTests lox-go test [--junit=file] dir...
+func runTests(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	junit := fs.String("junit", "", "write a JUnit XML report to `file`")
+	fs.Parse(args)
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	var results []*testrunner.FileResult
+	for _, root := range roots {
+		files, err := testrunner.Discover(root)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, file := range files {
+			results = append(results, testrunner.RunFile(file))
+		}
+	}
+
+	testrunner.WriteText(os.Stdout, results)
+
+	if *junit != "" {
+		f, err := os.Create(*junit)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := testrunner.WriteJUnit(f, results); err != nil {
+			log.Fatalln(err)
+		}
+		f.Close()
+	}
+
+	for _, fr := range results {
+		if fr.Err != nil || fr.Failed() > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// runConformance lox-go conformance [--cmd "prog args"] dir...
+func runConformance(args []string) {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	command := fs.String("cmd", "", "run each program with `command` instead of the built-in interpreter")
+	fs.Parse(args)
+
+	var backend conformance.Backend = conformance.Interpreter{}
+	if fields := strings.Fields(*command); len(fields) > 0 {
+		backend = &conformance.Command{Path: fields[0], Args: fields[1:]}
+	}
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		roots = []string{filepath.Join("testdata", "conformance")}
+	}
+
+	var results []*conformance.Result
+	for _, root := range roots {
+		rs, err := conformance.Run(backend, root)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		results = append(results, rs...)
+	}
+
+	if conformance.WriteReport(os.Stdout, backend, results) > 0 {
+		os.Exit(1)
+	}
+}
+
+// runFuzz lox-go fuzz [--target=parser] [--duration=10s] [--crashers=dir] seeddir...
+func runFuzz(args []string) {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	name := fs.String("target", "interpreter", "fuzz target: scanner, parser or interpreter")
+	duration := fs.Duration("duration", 10*time.Second, "how long to run")
+	crashers := fs.String("crashers", "crashers", "write crashing inputs to `dir`")
+	fs.Parse(args)
+
+	target, ok := fuzz.Targets[*name]
+	if !ok {
+		log.Fatalf("unknown fuzz target %q", *name)
+	}
+
+	var seeds [][]byte
+	for _, dir := range fs.Args() {
+		s, err := fuzz.LoadSeeds(dir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		seeds = append(seeds, s...)
+	}
+
+	f := fuzz.NewFuzzer(target, seeds)
+	f.Run(time.Now().Add(*duration))
+	if err := f.WriteCrashes(*crashers); err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("%s: %d execs, %d crashers\n", *name, f.Execs, len(f.Crashes))
+	if len(f.Crashes) > 0 {
+		os.Exit(1)
+	}
 }
 
 func main() {
-	runPrompt()
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		runPrompt()
+		return
+	}
+
+	switch args[0] {
+	case "lsp":
+		runLSP()
+	case "dap":
+		runDAP()
+	case "test":
+		runTests(args[1:])
+	case "conformance":
+		runConformance(args[1:])
+	case "fuzz":
+		runFuzz(args[1:])
+	default:
+		runFile(args[0], args[1:])
+	}
 }
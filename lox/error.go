@@ -12,3 +12,7 @@ type RuntimeError struct {
 func NewRuntimeError(tk *token.Token, msg string) *RuntimeError {
 	return &RuntimeError{Tk: tk, Msg: msg}
 }
+
+func (e *RuntimeError) Error() string {
+	return e.Msg
+}
@@ -2,10 +2,18 @@ package lox
 
 import (
 	"fmt"
+	"io"
+	"sync"
 
 	"github.com/zhiruchen/lox-go/token"
 )
 
+// 与参考实现 jlox 一致的退出码
+const (
+	ExitSyntaxError  = 65
+	ExitRuntimeError = 70
+)
+
 // Lox the lox lang
 type Lox struct {
 	HasError       bool
@@ -20,20 +28,79 @@ func runPromt() error {
 	return nil
 }
 
+// Reporter 按照参考实现 jlox 的格式输出错误, 可以被多个 goroutine 同时使用
+type Reporter struct {
+	Out             io.Writer
+	HadError        bool
+	HadRuntimeError bool
+
+	mu sync.Mutex
+}
+
+func NewReporter(out io.Writer) *Reporter {
+	return &Reporter{Out: out}
+}
+
+// Reset 清除 HadError 和 HadRuntimeError, 例如 REPL 在每一行之前调用
+func (r *Reporter) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.HadError = false
+	r.HadRuntimeError = false
+}
+
 // LineError lox line error
-func LineError(line int, message string) {
-	report(line, "", message)
+func (r *Reporter) LineError(line int, message string) {
+	r.report(line, "", message)
+}
+
+// ScanError 扫描错误, 在 jlox 的格式中加上列号: [line L:C] Error: message
+func (r *Reporter) ScanError(line, column int, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.HadError = true
+	fmt.Fprintf(r.Out, "[line %d:%d] Error: %s\n", line, column, message)
 }
 
 // TokenError lox token error
-func TokenError(tk *token.Token, message string) {
+func (r *Reporter) TokenError(tk *token.Token, message string) {
 	if tk.TokenType == token.Eof {
-		report(tk.Line, " is at end", message)
+		r.report(tk.Line, " at end", message)
 	} else {
-		report(tk.Line, " at '"+tk.Lexeme+"'", message)
+		r.report(tk.Line, " at '"+tk.Lexeme+"'", message)
+	}
+}
+
+// Warning 与 TokenError 格式相同, 但不设置 HadError
+func (r *Reporter) Warning(tk *token.Token, message string) {
+	where := " at '" + tk.Lexeme + "'"
+	if tk.TokenType == token.Eof {
+		where = " at end"
 	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.Out, "[line %d] Warning%s: %s\n", tk.Line, where, message)
 }
 
-func report(line int, where string, message string) {
-	fmt.Printf("[line %d] where: %s: %s\n", line, where, message)
+// RuntimeError 输出错误信息, 有 token 时在下一行输出行号
+func (r *Reporter) RuntimeError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.HadRuntimeError = true
+	if e, ok := err.(*RuntimeError); ok && e.Tk != nil {
+		fmt.Fprintf(r.Out, "%s\n[line %d]\n", e.Msg, e.Tk.Line)
+		return
+	}
+	fmt.Fprintf(r.Out, "%s\n", err)
+}
+
+func (r *Reporter) report(line int, where string, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.HadError = true
+	fmt.Fprintf(r.Out, "[line %d] Error%s: %s\n", line, where, message)
 }
@@ -0,0 +1,472 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// builtinSymbols cfg 授予的内置函数和模块, 按名字排序
+func builtinSymbols(cfg *interpreter.Config) []*symbol {
+	var symbols []*symbol
+	for name, v := range interpreter.Builtins(cfg) {
+		if fn, ok := v.(interpreter.Callable); ok {
+			symbols = append(symbols, &symbol{Name: name, Kind: SymbolKindFunction, Arity: fn.Arity()})
+		} else {
+			symbols = append(symbols, &symbol{Name: name, Kind: SymbolKindVariable, Arity: -1})
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Name < symbols[j].Name })
+	return symbols
+}
+
+// symbol 一个声明及其所有引用
+type symbol struct {
+	Name     string
+	Kind     int
+	Decl     *token.Token // 内置函数为 nil
+	Params   []*token.Token
+	Arity    int // 变量为 -1
+	Refs     []*token.Token
+	Children []*symbol
+}
+
+func (sym *symbol) hover() string {
+	if sym.Kind == SymbolKindFunction {
+		var params []string
+		for _, p := range sym.Params {
+			params = append(params, p.Lexeme)
+		}
+		if sym.Decl == nil && sym.Arity < 0 {
+			return fmt.Sprintf("native fun %s(...) — variadic", sym.Name)
+		}
+		if sym.Decl == nil {
+			return fmt.Sprintf("native fun %s() — arity %d", sym.Name, sym.Arity)
+		}
+		return fmt.Sprintf("fun %s(%s) — arity %d", sym.Name, strings.Join(params, ", "), sym.Arity)
+	}
+	return "var " + sym.Name
+}
+
+// occurrence 源码中一个标识符出现的位置
+type occurrence struct {
+	tk  *token.Token
+	sym *symbol
+}
+
+// analysis 一份文档的扫描, 解析和作用域解析的结果
+type analysis struct {
+	diagnostics []Diagnostic
+	symbols     []*symbol // 顶层声明, 嵌套声明在 Children 里
+	all         []*symbol
+	occurrences []occurrence
+
+	scopes  []map[string]*symbol
+	pending [][]function // 每个作用域中等到作用域结束时才解析的函数体
+	owner   []*symbol    // 当前正在解析的函数
+	bodies  []int        // owner 中每个函数的函数体所在的作用域
+}
+
+// function 一个函数声明和它的符号
+type function struct {
+	stmt *expr.Function
+	sym  *symbol
+}
+
+// analyze builtins 是全局作用域中预先定义的名字, 每次分析使用它们的拷贝记录引用
+func analyze(source string, builtins []*symbol) *analysis {
+	a := &analysis{}
+	lines := strings.Split(source, "\n")
+
+	s := scanner.NewScanner(source)
+	s.SetErrFunc(func(line, column int, msg string) {
+		start := Position{Line: line - 1, Character: column - 1}
+		a.diagnostics = append(a.diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}},
+			Severity: SeverityError,
+			Source:   "lox",
+			Message:  msg,
+		})
+	})
+	tokens := s.ScanTokens()
+
+	p := parser.NewParser(tokens, func(tk *token.Token, msg string) {
+		a.diagnostics = append(a.diagnostics, Diagnostic{
+			Range:    tokenRange(tk),
+			Severity: SeverityError,
+			Source:   "lox",
+			Message:  msg,
+		})
+	})
+	p.SetWarnFunc(a.warn)
+
+	var statements []expr.Stmt
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				a.diagnostics = append(a.diagnostics, Diagnostic{
+					Range:    lineRange(lines, tokens[len(tokens)-1].Line),
+					Severity: SeverityError,
+					Source:   "lox",
+					Message:  fmt.Sprintf("parser failed: %v", r),
+				})
+			}
+		}()
+		statements = p.Parse()
+	}()
+
+	a.beginScope()
+	for _, b := range builtins {
+		sym := *b
+		a.scopes[0][sym.Name] = &sym
+	}
+	a.resolveStmts(statements)
+	a.endScope()
+
+	return a
+}
+
+// lineRange 整行的范围, line 从 1 开始
+func lineRange(lines []string, line int) Range {
+	end := 0
+	if line-1 < len(lines) && line >= 1 {
+		end = len([]rune(lines[line-1]))
+	}
+	return Range{
+		Start: Position{Line: line - 1, Character: 0},
+		End:   Position{Line: line - 1, Character: end},
+	}
+}
+
+func tokenRange(tk *token.Token) Range {
+	start := Position{Line: tk.Line - 1, Character: tk.Column - 1}
+	end := start
+	end.Character += len([]rune(tk.Lexeme))
+	return Range{Start: start, End: end}
+}
+
+// lookup 查找 pos 处的标识符
+func (a *analysis) lookup(pos Position) *occurrence {
+	for i := range a.occurrences {
+		occ := &a.occurrences[i]
+		r := tokenRange(occ.tk)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (a *analysis) beginScope() {
+	a.scopes = append(a.scopes, map[string]*symbol{})
+	a.pending = append(a.pending, nil)
+}
+
+// endScope 先解析这个作用域中声明的函数体, 这样函数可以引用在它之后声明的名字
+func (a *analysis) endScope() {
+	top := len(a.scopes) - 1
+	for _, fn := range a.pending[top] {
+		a.resolveFunction(fn.stmt, fn.sym)
+	}
+	a.scopes = a.scopes[:top]
+	a.pending = a.pending[:top]
+}
+
+// declare 在当前作用域中声明 name; 参数, 模式和 select 的绑定等只用于解析引用, 不作为文档符号
+func (a *analysis) declare(name *token.Token, kind int) *symbol {
+	sym := &symbol{Name: name.Lexeme, Kind: kind, Decl: name, Arity: -1}
+	a.scopes[len(a.scopes)-1][name.Lexeme] = sym
+	a.all = append(a.all, sym)
+	a.occurrences = append(a.occurrences, occurrence{tk: name, sym: sym})
+	return sym
+}
+
+// declareSymbol 声明 var 或 fun; 直接在顶层或者函数体中时还作为文档符号, 嵌套的块中的不算
+func (a *analysis) declareSymbol(name *token.Token, kind int) *symbol {
+	sym := a.declare(name, kind)
+	top := len(a.scopes) - 1
+	switch {
+	case len(a.owner) == 0 && top == 0:
+		a.symbols = append(a.symbols, sym)
+	case len(a.owner) > 0 && top == a.bodies[len(a.bodies)-1]:
+		parent := a.owner[len(a.owner)-1]
+		parent.Children = append(parent.Children, sym)
+	}
+	return sym
+}
+
+// reference 由内向外查找 name 的声明, 找不到时忽略
+func (a *analysis) reference(name *token.Token) {
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if sym, ok := a.scopes[i][name.Lexeme]; ok {
+			sym.Refs = append(sym.Refs, name)
+			a.occurrences = append(a.occurrences, occurrence{tk: name, sym: sym})
+			return
+		}
+	}
+}
+
+func (a *analysis) resolveStmts(statements []expr.Stmt) {
+	for _, stmt := range statements {
+		if stmt != nil {
+			stmt.Accept(a)
+		}
+	}
+}
+
+func (a *analysis) resolveExpr(e expr.Expr) {
+	if e != nil {
+		e.Accept(a)
+	}
+}
+
+func (a *analysis) VisitorBinaryExpr(e *expr.Binary) interface{} {
+	a.resolveExpr(e.Left)
+	a.resolveExpr(e.Right)
+	return nil
+}
+
+func (a *analysis) VisitorGroupingExpr(e *expr.Grouping) interface{} {
+	a.resolveExpr(e.Expression)
+	return nil
+}
+
+func (a *analysis) VisitorLiteralExpr(e *expr.Literal) interface{} {
+	return nil
+}
+
+func (a *analysis) VisitorInterpolationExpr(e *expr.Interpolation) interface{} {
+	for _, part := range e.Parts {
+		a.resolveExpr(part)
+	}
+	return nil
+}
+
+func (a *analysis) VisitorLogicalExpr(e *expr.Logical) interface{} {
+	a.resolveExpr(e.Left)
+	a.resolveExpr(e.Right)
+	return nil
+}
+
+func (a *analysis) VisitorGetExpr(e *expr.Get) interface{} {
+	a.resolveExpr(e.Object)
+	return nil
+}
+
+func (a *analysis) VisitorSetExpr(e *expr.Set) interface{} {
+	a.resolveExpr(e.Object)
+	a.resolveExpr(e.Value)
+	return nil
+}
+
+func (a *analysis) VisitorMapExpr(e *expr.Map) interface{} {
+	for i := range e.Keys {
+		a.resolveExpr(e.Keys[i])
+		a.resolveExpr(e.Values[i])
+	}
+	return nil
+}
+
+func (a *analysis) VisitorListExpr(e *expr.List) interface{} {
+	for _, element := range e.Elements {
+		a.resolveExpr(element)
+	}
+	return nil
+}
+
+func (a *analysis) VisitorConditionalExpr(e *expr.Conditional) interface{} {
+	a.resolveExpr(e.Condition)
+	a.resolveExpr(e.Then)
+	a.resolveExpr(e.Else)
+	return nil
+}
+
+func (a *analysis) VisitorUnaryExpr(e *expr.Unary) interface{} {
+	a.resolveExpr(e.Right)
+	return nil
+}
+
+func (a *analysis) VisitorVariableExpr(e *expr.Variable) interface{} {
+	a.reference(e.Name)
+	return nil
+}
+
+func (a *analysis) VisitorUpdateExpr(e *expr.Update) interface{} {
+	if e.Object != nil {
+		a.resolveExpr(e.Object)
+		return nil
+	}
+	a.reference(e.Name)
+	return nil
+}
+
+func (a *analysis) VisitorAssignExpr(e *expr.Assign) interface{} {
+	a.resolveExpr(e.Value)
+	a.reference(e.Name)
+	return nil
+}
+
+func (a *analysis) VisitorCallExpr(e *expr.Call) interface{} {
+	a.resolveExpr(e.Callee)
+	for _, arg := range e.Arguments {
+		a.resolveExpr(arg)
+	}
+	return nil
+}
+
+func (a *analysis) VisitorExpressionStmtExpr(stmt *expr.Expression) interface{} {
+	a.resolveExpr(stmt.Expression)
+	return nil
+}
+
+func (a *analysis) VisitorPrintStmtExpr(stmt *expr.Print) interface{} {
+	a.resolveExpr(stmt.Print)
+	return nil
+}
+
+func (a *analysis) VisitorReturnStmtExpr(stmt *expr.Return) interface{} {
+	a.resolveExpr(stmt.Value)
+	return nil
+}
+
+func (a *analysis) VisitorYieldStmtExpr(stmt *expr.Yield) interface{} {
+	a.resolveExpr(stmt.Value)
+	return nil
+}
+
+func (a *analysis) VisitorVarStmtExpr(stmt *expr.Var) interface{} {
+	a.resolveExpr(stmt.Initializer)
+	a.declareSymbol(stmt.Name, SymbolKindVariable)
+	return nil
+}
+
+func (a *analysis) VisitorWhileStmtExpr(stmt *expr.While) interface{} {
+	a.resolveExpr(stmt.Condition)
+	a.resolveStmts([]expr.Stmt{stmt.Body})
+	return nil
+}
+
+func (a *analysis) VisitorForInStmtExpr(stmt *expr.ForIn) interface{} {
+	a.resolveExpr(stmt.Iterable)
+	a.beginScope()
+	a.declare(stmt.Name, SymbolKindVariable)
+	a.resolveStmts([]expr.Stmt{stmt.Body})
+	a.endScope()
+	return nil
+}
+
+func (a *analysis) VisitorBlockStmtExpr(stmt *expr.Block) interface{} {
+	a.beginScope()
+	a.resolveStmts(stmt.Statements)
+	a.endScope()
+	return nil
+}
+
+func (a *analysis) VisitorIFStmtExpr(stmt *expr.IF) interface{} {
+	a.resolveExpr(stmt.Condition)
+	a.resolveStmts([]expr.Stmt{stmt.ThenBranch, stmt.ElseBranch})
+	return nil
+}
+
+func (a *analysis) VisitorFunStmtExpr(stmt *expr.Function) interface{} {
+	sym := a.declareSymbol(stmt.Name, SymbolKindFunction)
+	sym.Params = stmt.Parameters
+	sym.Arity = len(stmt.Parameters)
+
+	top := len(a.pending) - 1
+	a.pending[top] = append(a.pending[top], function{stmt: stmt, sym: sym})
+	return nil
+}
+
+func (a *analysis) resolveFunction(stmt *expr.Function, sym *symbol) {
+	a.owner = append(a.owner, sym)
+	a.beginScope()
+	a.bodies = append(a.bodies, len(a.scopes)-1)
+	for _, param := range stmt.Parameters {
+		a.declare(param, SymbolKindVariable)
+	}
+	a.resolveStmts(stmt.Body)
+	a.endScope()
+	a.bodies = a.bodies[:len(a.bodies)-1]
+	a.owner = a.owner[:len(a.owner)-1]
+}
+
+func (a *analysis) VisitorAssertStmtExpr(stmt *expr.Assert) interface{} {
+	a.resolveExpr(stmt.Condition)
+	a.resolveExpr(stmt.Message)
+	return nil
+}
+
+func (a *analysis) VisitorTestStmtExpr(stmt *expr.Test) interface{} {
+	a.beginScope()
+	a.resolveStmts(stmt.Body)
+	a.endScope()
+	return nil
+}
+
+func (a *analysis) VisitorMatchStmtExpr(stmt *expr.Match) interface{} {
+	a.resolveExpr(stmt.Value)
+
+	for _, c := range stmt.Cases {
+		a.beginScope()
+		for _, pattern := range c.Patterns {
+			a.resolvePattern(pattern)
+		}
+		a.resolveExpr(c.Guard)
+		a.resolveStmts([]expr.Stmt{c.Body})
+		a.endScope()
+	}
+	return nil
+}
+
+func (a *analysis) resolvePattern(pattern expr.Pattern) {
+	switch p := pattern.(type) {
+	case *expr.LiteralPattern:
+		a.resolveExpr(p.Value)
+	case *expr.BindingPattern:
+		a.declare(p.Name, SymbolKindVariable)
+	case *expr.ListPattern:
+		for _, element := range p.Elements {
+			a.resolvePattern(element)
+		}
+	case *expr.ShapePattern:
+		for _, field := range p.Fields {
+			a.resolvePattern(field)
+		}
+	}
+}
+
+func (a *analysis) warn(tk *token.Token, msg string) {
+	a.diagnostics = append(a.diagnostics, Diagnostic{
+		Range:    tokenRange(tk),
+		Severity: SeverityWarning,
+		Source:   "lox",
+		Message:  msg,
+	})
+}
+
+func (a *analysis) VisitorSpawnStmtExpr(stmt *expr.Spawn) interface{} {
+	a.resolveExpr(stmt.Call)
+	return nil
+}
+
+func (a *analysis) VisitorSelectStmtExpr(stmt *expr.Select) interface{} {
+	for _, c := range stmt.Cases {
+		a.resolveExpr(c.Channel)
+		a.resolveExpr(c.Value)
+
+		a.beginScope()
+		if c.Name != nil {
+			a.declare(c.Name, SymbolKindVariable)
+		}
+		a.resolveStmts([]expr.Stmt{c.Body})
+		a.endScope()
+	}
+	return nil
+}
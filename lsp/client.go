@@ -0,0 +1,132 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+// Client 一个最小的 LSP client, 用来在进程内驱动 Server
+type Client struct {
+	conn *conn
+
+	mu            sync.Mutex
+	nextID        int
+	pending       map[string]chan *message
+	notifications chan *message
+	err           error
+}
+
+// NewClient 从 in 读取 server 的消息, 向 out 写入请求
+func NewClient(in io.Reader, out io.Writer) *Client {
+	c := &Client{
+		conn:          newConn(in, out),
+		pending:       make(map[string]chan *message),
+		notifications: make(chan *message, 64),
+	}
+	go c.loop()
+	return c
+}
+
+// Pipe 返回通过内存管道相连的 client 和 server, 调用方负责运行 server.Serve
+func Pipe() (*Client, *Server) {
+	return PipeWithConfig(interpreter.FullAccess())
+}
+
+// PipeWithConfig 与 Pipe 相同, 但是 server 由 NewServerWithConfig 创建
+func PipeWithConfig(cfg *interpreter.Config) (*Client, *Server) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	return NewClient(clientR, clientW), NewServerWithConfig(serverR, serverW, cfg)
+}
+
+func (c *Client) loop() {
+	for {
+		msg, err := c.conn.read()
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			close(c.notifications)
+			return
+		}
+
+		if msg.isResponse() {
+			c.mu.Lock()
+			ch, ok := c.pending[string(*msg.ID)]
+			delete(c.pending, string(*msg.ID))
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+			continue
+		}
+
+		c.notifications <- msg
+	}
+}
+
+// Call 发送请求并等待响应, 结果解码到 result 中
+func (c *Client) Call(method string, params, result interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	ch := make(chan *message, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: raw}); err != nil {
+		return err
+	}
+
+	resp, ok := <-ch
+	if !ok {
+		return errors.New("connection closed")
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// Notify 发送通知
+func (c *Client) Notify(method string, params interface{}) error {
+	return c.conn.notify(method, params)
+}
+
+// Diagnostics 等待下一个 publishDiagnostics 通知
+func (c *Client) Diagnostics() (*PublishDiagnosticsParams, error) {
+	for msg := range c.notifications {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return &params, nil
+	}
+	return nil, errors.New("connection closed")
+}
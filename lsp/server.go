@@ -0,0 +1,293 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/parser"
+)
+
+// keywords 补全时提示的关键字, 只包括解析器接受的
+var keywords = parser.Keywords()
+
+// Server 基于 stdio 的 Lox language server
+type Server struct {
+	conn      *conn
+	documents map[string]*analysis
+	builtins  []*symbol
+	shutdown  bool
+}
+
+// NewServer 从 in 读取请求, 向 out 写入响应和通知; 与命令行的默认值一样认为脚本有所有能力
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return NewServerWithConfig(in, out, interpreter.FullAccess())
+}
+
+// NewServerWithConfig 只把 cfg 授予的内置函数和模块当作已定义的全局名字, 并且只补全它们
+func NewServerWithConfig(in io.Reader, out io.Writer, cfg *interpreter.Config) *Server {
+	return &Server{
+		conn:      newConn(in, out),
+		documents: make(map[string]*analysis),
+		builtins:  builtinSymbols(cfg),
+	}
+}
+
+// Serve 处理消息直到收到 exit 通知或者输入结束
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if respErr, ok := err.(*ResponseError); ok {
+			if err := s.conn.reply(nil, nil, respErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	if msg.isResponse() {
+		return nil
+	}
+
+	result, respErr := s.dispatch(msg)
+	if !msg.isRequest() {
+		return nil
+	}
+	return s.conn.reply(msg.ID, result, respErr)
+}
+
+func (s *Server) dispatch(msg *message) (interface{}, *ResponseError) {
+	if s.shutdown && msg.isRequest() {
+		return nil, &ResponseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var result InitializeResult
+		result.ServerInfo.Name = "lox-go"
+		result.Capabilities = ServerCapabilities{
+			TextDocumentSync:       syncFull,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     map[string]interface{}{},
+		}
+		return result, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, nil)
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.references(params), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(params), nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.documentSymbols(params.TextDocument.URI), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.completion(params.TextDocument.URI), nil
+	}
+
+	return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func invalidParams(err error) *ResponseError {
+	return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// update 重新分析文档并发布诊断信息
+func (s *Server) update(uri, text string) *ResponseError {
+	a := analyze(text, s.builtins)
+	s.documents[uri] = a
+	return s.publish(uri, a.diagnostics)
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) *ResponseError {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+
+	err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+	if err != nil {
+		return &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) symbolAt(params TextDocumentPositionParams) *symbol {
+	a, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	occ := a.lookup(params.Position)
+	if occ == nil {
+		return nil
+	}
+	return occ.sym
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) []Location {
+	sym := s.symbolAt(params)
+	if sym == nil || sym.Decl == nil {
+		return []Location{}
+	}
+	return []Location{{URI: params.TextDocument.URI, Range: tokenRange(sym.Decl)}}
+}
+
+func (s *Server) references(params ReferenceParams) []Location {
+	locations := []Location{}
+
+	sym := s.symbolAt(params.TextDocumentPositionParams)
+	if sym == nil {
+		return locations
+	}
+
+	uri := params.TextDocument.URI
+	if params.Context.IncludeDeclaration && sym.Decl != nil {
+		locations = append(locations, Location{URI: uri, Range: tokenRange(sym.Decl)})
+	}
+	for _, ref := range sym.Refs {
+		locations = append(locations, Location{URI: uri, Range: tokenRange(ref)})
+	}
+	return locations
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	a, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	occ := a.lookup(params.Position)
+	if occ == nil {
+		return nil
+	}
+
+	r := tokenRange(occ.tk)
+	return &Hover{
+		Contents: MarkupContent{Kind: "plaintext", Value: occ.sym.hover()},
+		Range:    &r,
+	}
+}
+
+func (s *Server) documentSymbols(uri string) []DocumentSymbol {
+	a, ok := s.documents[uri]
+	if !ok {
+		return []DocumentSymbol{}
+	}
+	return toDocumentSymbols(a.symbols)
+}
+
+func toDocumentSymbols(symbols []*symbol) []DocumentSymbol {
+	result := []DocumentSymbol{}
+	for _, sym := range symbols {
+		r := tokenRange(sym.Decl)
+		ds := DocumentSymbol{
+			Name:           sym.Name,
+			Kind:           sym.Kind,
+			Range:          r,
+			SelectionRange: r,
+		}
+		if sym.Kind == SymbolKindFunction {
+			ds.Detail = sym.hover()
+			ds.Children = toDocumentSymbols(sym.Children)
+		}
+		result = append(result, ds)
+	}
+	return result
+}
+
+func (s *Server) completion(uri string) CompletionList {
+	list := CompletionList{Items: []CompletionItem{}}
+	for _, kw := range keywords {
+		list.Items = append(list.Items, CompletionItem{Label: kw, Kind: CompletionKindKeyword})
+	}
+
+	seen := map[string]bool{}
+	names := append([]*symbol(nil), s.builtins...)
+	if a, ok := s.documents[uri]; ok {
+		names = append(names, a.all...)
+	}
+	sort.SliceStable(names, func(i, j int) bool { return names[i].Name < names[j].Name })
+
+	for _, sym := range names {
+		if seen[sym.Name] {
+			continue
+		}
+		seen[sym.Name] = true
+
+		kind := CompletionKindVariable
+		if sym.Kind == SymbolKindFunction {
+			kind = CompletionKindFunction
+		}
+		list.Items = append(list.Items, CompletionItem{Label: sym.Name, Kind: kind, Detail: sym.hover()})
+	}
+	return list
+}
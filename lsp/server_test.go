@@ -0,0 +1,336 @@
+package lsp_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lsp"
+	"github.com/zhiruchen/lox-go/parser"
+)
+
+const uri = "file:///test.lox"
+
+// start 运行一个有所有能力的 server, 打开内容为 source 的文档, 返回 client 和第一次发布的诊断信息
+func start(t *testing.T, source string) (*lsp.Client, []lsp.Diagnostic) {
+	t.Helper()
+	return startWithConfig(t, interpreter.FullAccess(), source)
+}
+
+func startWithConfig(t *testing.T, cfg *interpreter.Config, source string) (*lsp.Client, []lsp.Diagnostic) {
+	t.Helper()
+
+	client, server := lsp.PipeWithConfig(cfg)
+	done := make(chan error, 1)
+	go func() { done <- server.Serve() }()
+	t.Cleanup(func() {
+		client.Call("shutdown", nil, nil)
+		client.Notify("exit", nil)
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+
+	var result lsp.InitializeResult
+	if err := client.Call("initialize", map[string]interface{}{}, &result); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if result.ServerInfo.Name != "lox-go" || !result.Capabilities.DefinitionProvider {
+		t.Fatalf("unexpected initialize result %+v", result)
+	}
+
+	err := client.Notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "lox", Version: 1, Text: source},
+	})
+	if err != nil {
+		t.Fatalf("didOpen: %v", err)
+	}
+	params, err := client.Diagnostics()
+	if err != nil {
+		t.Fatalf("diagnostics: %v", err)
+	}
+	if params.URI != uri {
+		t.Fatalf("diagnostics for %s, want %s", params.URI, uri)
+	}
+	return client, params.Diagnostics
+}
+
+func position(line, character int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: character},
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	client, diagnostics := start(t, "var a = 1;\nprint a +;\n")
+	if len(diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %+v", len(diagnostics), diagnostics)
+	}
+	d := diagnostics[0]
+	if d.Severity != lsp.SeverityError || d.Message != "Expect expression." || d.Range.Start.Line != 1 {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	// 修复之后发布空的诊断信息
+	err := client.Notify("textDocument/didChange", lsp.DidChangeTextDocumentParams{
+		TextDocument:   lsp.TextDocumentIdentifier{URI: uri},
+		ContentChanges: []lsp.TextDocumentContentChangeEvent{{Text: "var a = 1;\nprint a;\n"}},
+	})
+	if err != nil {
+		t.Fatalf("didChange: %v", err)
+	}
+	params, err := client.Diagnostics()
+	if err != nil {
+		t.Fatalf("diagnostics: %v", err)
+	}
+	if len(params.Diagnostics) != 0 {
+		t.Errorf("got diagnostics %+v after fix, want none", params.Diagnostics)
+	}
+}
+
+// TestTopLevelReturnDiagnostic 顶层的 return 是语法错误, 范围是 return 关键字
+func TestTopLevelReturnDiagnostic(t *testing.T) {
+	_, diagnostics := start(t, "print 1;\nreturn;\n")
+	if len(diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %+v", len(diagnostics), diagnostics)
+	}
+	d := diagnostics[0]
+	want := lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 6}}
+	if d.Message != "Cannot return from top-level code." || d.Range != want {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+}
+
+func TestUnreachableCaseWarning(t *testing.T) {
+	_, diagnostics := start(t, "match (1) {\n  case _ => print 1;\n  case 2 => print 2;\n}\n")
+	if len(diagnostics) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %+v", len(diagnostics), diagnostics)
+	}
+	d := diagnostics[0]
+	if d.Severity != lsp.SeverityWarning || d.Message != "Case can never match." {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+	if want := (lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 6}}); d.Range != want {
+		t.Errorf("got range %+v, want %+v", d.Range, want)
+	}
+}
+
+const program = `fun add(a, b) {
+  return a + b;
+}
+var total = add(1, 2);
+print add(total, 3);
+`
+
+func TestDefinitionAndReferences(t *testing.T) {
+	client, _ := start(t, program)
+
+	// print add(total, 3) 中的 add
+	var locations []lsp.Location
+	if err := client.Call("textDocument/definition", position(4, 7), &locations); err != nil {
+		t.Fatalf("definition: %v", err)
+	}
+	if len(locations) != 1 || locations[0].Range.Start != (lsp.Position{Line: 0, Character: 4}) {
+		t.Errorf("got definition %+v, want fun add on line 0", locations)
+	}
+
+	params := lsp.ReferenceParams{TextDocumentPositionParams: position(0, 4)}
+	params.Context.IncludeDeclaration = true
+	if err := client.Call("textDocument/references", params, &locations); err != nil {
+		t.Fatalf("references: %v", err)
+	}
+	if len(locations) != 3 {
+		t.Errorf("got %d references to add, want 3: %+v", len(locations), locations)
+	}
+
+	// 内置函数没有定义的位置
+	if err := client.Call("textDocument/definition", position(10, 0), &locations); err != nil {
+		t.Fatalf("definition: %v", err)
+	}
+	if len(locations) != 0 {
+		t.Errorf("got definition %+v for empty position, want none", locations)
+	}
+}
+
+func TestForwardReference(t *testing.T) {
+	client, _ := start(t, "fun a() {\n  return b();\n}\nfun b() {\n  return c;\n}\nvar c = 1;\n")
+
+	// a 的函数体中的 b 指向之后声明的 fun b
+	var locations []lsp.Location
+	if err := client.Call("textDocument/definition", position(1, 9), &locations); err != nil {
+		t.Fatalf("definition: %v", err)
+	}
+	if len(locations) != 1 || locations[0].Range.Start != (lsp.Position{Line: 3, Character: 4}) {
+		t.Errorf("got definition %+v, want fun b on line 3", locations)
+	}
+
+	params := lsp.ReferenceParams{TextDocumentPositionParams: position(6, 4)}
+	params.Context.IncludeDeclaration = true
+	if err := client.Call("textDocument/references", params, &locations); err != nil {
+		t.Fatalf("references: %v", err)
+	}
+	if len(locations) != 2 {
+		t.Errorf("got %d references to c, want 2: %+v", len(locations), locations)
+	}
+}
+
+func TestHover(t *testing.T) {
+	client, _ := start(t, program)
+
+	var hover lsp.Hover
+	if err := client.Call("textDocument/hover", position(3, 13), &hover); err != nil {
+		t.Fatalf("hover: %v", err)
+	}
+	if want := "fun add(a, b) — arity 2"; hover.Contents.Value != want {
+		t.Errorf("got hover %q, want %q", hover.Contents.Value, want)
+	}
+
+	if err := client.Call("textDocument/hover", position(4, 11), &hover); err != nil {
+		t.Fatalf("hover: %v", err)
+	}
+	if want := "var total"; hover.Contents.Value != want {
+		t.Errorf("got hover %q, want %q", hover.Contents.Value, want)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	client, _ := start(t, program)
+
+	var symbols []lsp.DocumentSymbol
+	err := client.Call("textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, &symbols)
+	if err != nil {
+		t.Fatalf("documentSymbol: %v", err)
+	}
+	if len(symbols) != 2 || symbols[0].Name != "add" || symbols[0].Kind != lsp.SymbolKindFunction || symbols[1].Name != "total" {
+		t.Errorf("unexpected symbols %+v", symbols)
+	}
+}
+
+// TestDocumentSymbolsOutline 只有顶层和函数体中的 var 和 fun 是文档符号, 参数和各种绑定不是
+func TestDocumentSymbolsOutline(t *testing.T) {
+	client, _ := start(t, `fun outer(p) {
+  var local = p;
+  fun inner() {}
+  if (local) { var nested = 1; }
+  for (var x in [1]) print x;
+  match (p) { case [y] => print y; }
+}
+{ var block = 1; }
+`)
+
+	var symbols []lsp.DocumentSymbol
+	err := client.Call("textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, &symbols)
+	if err != nil {
+		t.Fatalf("documentSymbol: %v", err)
+	}
+	if len(symbols) != 1 || symbols[0].Name != "outer" {
+		t.Fatalf("unexpected symbols %+v", symbols)
+	}
+	var children []string
+	for _, child := range symbols[0].Children {
+		children = append(children, child.Name)
+	}
+	if want := []string{"local", "inner"}; !reflect.DeepEqual(children, want) {
+		t.Errorf("got children %v, want %v", children, want)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	client, _ := start(t, program)
+
+	var list lsp.CompletionList
+	if err := client.Call("textDocument/completion", position(5, 0), &list); err != nil {
+		t.Fatalf("completion: %v", err)
+	}
+	labels := map[string]int{}
+	for _, item := range list.Items {
+		labels[item.Label] = item.Kind
+	}
+	want := map[string]int{
+		"while": lsp.CompletionKindKeyword,
+		"spawn": lsp.CompletionKindKeyword,
+		"yield": lsp.CompletionKindKeyword,
+		"in":    lsp.CompletionKindKeyword,
+		"match": lsp.CompletionKindKeyword,
+		"clock": lsp.CompletionKindFunction,
+		"math":  lsp.CompletionKindVariable,
+		"add":   lsp.CompletionKindFunction,
+		"total": lsp.CompletionKindVariable,
+	}
+	for label, kind := range want {
+		if labels[label] != kind {
+			t.Errorf("completion %q: got kind %d, want %d", label, labels[label], kind)
+		}
+	}
+}
+
+// TestCompletionCapabilities 只补全 cfg 授予的内置函数和模块
+func TestCompletionCapabilities(t *testing.T) {
+	client, _ := startWithConfig(t, &interpreter.Config{}, "")
+
+	var list lsp.CompletionList
+	if err := client.Call("textDocument/completion", position(0, 0), &list); err != nil {
+		t.Fatalf("completion: %v", err)
+	}
+	labels := map[string]int{}
+	for _, item := range list.Items {
+		labels[item.Label] = item.Kind
+	}
+	for label, kind := range map[string]int{"math": lsp.CompletionKindVariable, "range": lsp.CompletionKindFunction} {
+		if labels[label] != kind {
+			t.Errorf("completion %q: got kind %d, want %d", label, labels[label], kind)
+		}
+	}
+	for _, label := range []string{"clock", "fs", "os", "time", "net", "env"} {
+		if _, ok := labels[label]; ok {
+			t.Errorf("completion %q offered without its capability", label)
+		}
+	}
+}
+
+// TestCompletionKeywords 提示的关键字就是解析器接受的那些, 包括 test 而不包括未实现的 class
+func TestCompletionKeywords(t *testing.T) {
+	client, _ := start(t, "")
+
+	var list lsp.CompletionList
+	if err := client.Call("textDocument/completion", position(0, 0), &list); err != nil {
+		t.Fatalf("completion: %v", err)
+	}
+	var got []string
+	for _, item := range list.Items {
+		if item.Kind == lsp.CompletionKindKeyword {
+			got = append(got, item.Label)
+		}
+	}
+	if want := parser.Keywords(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keywords %v, want %v", got, want)
+	}
+	for _, word := range []string{"test", "in", "match"} {
+		if !contains(got, word) {
+			t.Errorf("keyword %q not offered", word)
+		}
+	}
+	for _, word := range []string{"class", "super", "this"} {
+		if contains(got, word) {
+			t.Errorf("unimplemented keyword %q offered", word)
+		}
+	}
+}
+
+func contains(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMethodNotFound(t *testing.T) {
+	client, _ := start(t, "")
+
+	if err := client.Call("textDocument/rename", position(0, 0), nil); err == nil {
+		t.Error("got no error for an unsupported method")
+	}
+}
@@ -2,21 +2,72 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
 
 	"github.com/zhiruchen/lox-go/expr"
-	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/scanner"
 	"github.com/zhiruchen/lox-go/token"
 )
 
 type ErrFunc func(tk *token.Token, msg string)
 
+// ParseError 语法错误, 已经通过 errFunc 报告过
+type ParseError struct {
+	Tk  *token.Token
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return e.Msg
+}
+
+// unimplemented 扫描器保留但是语法中还没有用到的关键字
+var unimplemented = map[string]bool{"class": true, "super": true, "this": true}
+
+// contextual 扫描成 Identifier, 只在特定位置被当作关键字的词, 见 checkTest 和 checkIn
+var contextual = []string{"in", "test"}
+
+// Keywords 语法中用到的所有关键字, 包括上下文关键字, 按字母排序
+func Keywords() []string {
+	var words []string
+	for _, word := range scanner.Keywords() {
+		if !unimplemented[word] {
+			words = append(words, word)
+		}
+	}
+	words = append(words, contextual...)
+	sort.Strings(words)
+	return words
+}
+
+// MaxNesting 语句和表达式的最大嵌套层数, 避免恶意输入导致栈溢出
+const MaxNesting = 256
+
 type Parser struct {
-	tokens  []*token.Token
-	current int
-	errFunc ErrFunc
+	tokens   []*token.Token
+	current  int
+	depth    int
+	errFunc  ErrFunc
+	warnFunc ErrFunc
+	hadError bool
+
+	// generators 正在解析的函数, 由内向外, 函数体中出现 yield 时为 true; 为空时在顶层
+	generators []bool
+	// inTest 正在解析 test 的主体并且不在其中的函数里
+	inTest bool
 }
 
+// NewParser tokens 不以 Eof 结尾时会补上一个
 func NewParser(tokens []*token.Token, errFunc ErrFunc) *Parser {
+	if n := len(tokens); n == 0 || tokens[n-1].TokenType != token.Eof {
+		eof := &token.Token{TokenType: token.Eof, Line: 1}
+		if n > 0 {
+			eof.Line = tokens[n-1].Line
+		}
+		tokens = append(tokens[:n:n], eof)
+	}
 	return &Parser{tokens: tokens, errFunc: errFunc}
 }
 
@@ -24,22 +75,50 @@ func (p *Parser) Parse() []expr.Stmt {
 
 	statements := make([]expr.Stmt, 0)
 	for !p.isAtEnd() {
-		statements = append(statements, p.declaration())
+		if stmt := p.declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 
 	return statements
 }
 
-func (p *Parser) declaration() expr.Stmt {
-	if p.match(token.Var) {
-		return p.varDeclaration()
-	}
+// SetWarnFunc 设置警告的回调, 例如永远不会匹配的 case; 警告不影响 HadError
+func (p *Parser) SetWarnFunc(warnFunc ErrFunc) {
+	p.warnFunc = warnFunc
+}
+
+// HadError 是否报告过语法错误
+func (p *Parser) HadError() bool {
+	return p.hadError
+}
 
-	if p.match(token.Fun) {
-		return p.function("function")
+// declaration 出错时跳到下一条语句, 返回 nil
+func (p *Parser) declaration() (stmt expr.Stmt) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*ParseError); !ok {
+				panic(r)
+			}
+			p.synchronize()
+			stmt = nil
+		}
+	}()
+
+	line := p.peek().Line
+	if p.match(token.Var) {
+		stmt = p.varDeclaration()
+	} else if p.match(token.Fun) {
+		stmt = p.function("function")
+	} else if p.checkTest() {
+		p.advance()
+		stmt = p.testDeclaration()
+	} else {
+		return p.statement()
 	}
 
-	return p.statement()
+	stmt.SetLine(line)
+	return stmt
 }
 
 func (p *Parser) varDeclaration() expr.Stmt {
@@ -55,29 +134,72 @@ func (p *Parser) varDeclaration() expr.Stmt {
 }
 
 func (p *Parser) function(kind string) *expr.Function {
-	name := p.consume(token.Identifier, "expect "+kind+"name.")
-	p.consume(token.LeftParen, "Expect `(` after "+kind+" name.")
+	name := p.consume(token.Identifier, "Expect "+kind+" name.")
+	p.consume(token.LeftParen, "Expect '(' after "+kind+" name.")
 
 	var params []*token.Token
 	if !p.check(token.RightParen) {
 		params = append(params, p.consume(token.Identifier, "Expect parameter name."))
 
 		for p.match(token.Comma) {
-			if len(params) > 8 {
-				p.errFunc(p.peek(), "Cannot have more than 8 parameters.")
+			if len(params) >= 8 {
+				p.error(p.peek(), "Cannot have more than 8 parameters.")
 			}
 
 			params = append(params, p.consume(token.Identifier, "Expect parameter name."))
 		}
 	}
-	p.consume(token.RightParen, "Expect `)` after parameters")
+	p.consume(token.RightParen, "Expect ')' after parameters.")
 
-	p.consume(token.LeftBrace, "Expect `{` before "+kind+" body.")
-	body := p.block()
-	return expr.NewFunctionStmt(name, params, body)
+	p.consume(token.LeftBrace, "Expect '{' before "+kind+" body.")
+
+	p.generators = append(p.generators, false)
+	inTest := p.inTest
+	p.inTest = false
+	defer func() {
+		p.generators = p.generators[:len(p.generators)-1]
+		p.inTest = inTest
+	}()
+
+	function := expr.NewFunctionStmt(name, params, p.block())
+	function.Generator = p.generators[len(p.generators)-1]
+	return function
+}
+
+// checkTest test 不是关键字, 只有后面跟着字符串时才是测试声明
+func (p *Parser) checkTest() bool {
+	if !p.check(token.Identifier) || p.peek().Lexeme != "test" {
+		return false
+	}
+	return p.tokens[p.current+1].TokenType == token.String
+}
+
+// testDeclaration 只能出现在顶层, 否则 test runner 找不到它
+func (p *Parser) testDeclaration() expr.Stmt {
+	keyword := p.previous()
+	if p.depth > 0 {
+		p.error(keyword, "Test declarations must be at the top level.")
+	}
+	name := p.consume(token.String, "Expect test name.")
+	p.consume(token.LeftBrace, "Expect '{' before test body.")
+
+	p.inTest = true
+	defer func() {
+		p.inTest = false
+	}()
+	return expr.NewTestStmt(name, p.block())
 }
 
 func (p *Parser) statement() expr.Stmt {
+	defer p.nest()()
+
+	line := p.peek().Line
+	stmt := p.parseStatement()
+	stmt.SetLine(line)
+	return stmt
+}
+
+func (p *Parser) parseStatement() expr.Stmt {
 	if p.match(token.For) {
 		return p.forStatement()
 	}
@@ -90,14 +212,34 @@ func (p *Parser) statement() expr.Stmt {
 		return p.printStatement()
 	}
 
+	if p.match(token.Assert) {
+		return p.assertStatement()
+	}
+
 	if p.match(token.Return) {
 		return p.returnStatement()
 	}
 
+	if p.match(token.Yield) {
+		return p.yieldStatement()
+	}
+
 	if p.match(token.While) {
 		return p.whileStatement()
 	}
 
+	if p.match(token.Match) {
+		return p.matchStatement()
+	}
+
+	if p.match(token.Spawn) {
+		return p.spawnStatement()
+	}
+
+	if p.match(token.Select) {
+		return p.selectStatement()
+	}
+
 	if p.match(token.LeftBrace) {
 		return expr.NewBlockStmt(p.block())
 	}
@@ -106,7 +248,12 @@ func (p *Parser) statement() expr.Stmt {
 }
 
 func (p *Parser) forStatement() expr.Stmt {
-	p.consume(token.LeftParen, "Expect `(` after for.")
+	line := p.previous().Line
+	p.consume(token.LeftParen, "Expect '(' after 'for'.")
+
+	if p.checkIn() {
+		return p.forInStatement()
+	}
 
 	var initializer expr.Stmt
 
@@ -117,40 +264,69 @@ func (p *Parser) forStatement() expr.Stmt {
 	} else {
 		initializer = p.expressionStatement()
 	}
+	if initializer != nil {
+		initializer.SetLine(line)
+	}
 
 	var cond expr.Expr
 	if !p.check(token.Semicolon) {
 		cond = p.expression()
 	}
-	p.consume(token.Semicolon, "Expect `;` after loop condition.")
+	p.consume(token.Semicolon, "Expect ';' after loop condition.")
 
 	var increment expr.Expr
+	incrementLine := p.peek().Line
 	if !p.check(token.RightParen) {
 		increment = p.expression()
 	}
-	p.consume(token.RightParen, "Expect `)` after for clauses.")
+	p.consume(token.RightParen, "Expect ')' after for clauses.")
 	body := p.statement()
 
 	if increment != nil {
-		body = expr.NewBlockStmt([]expr.Stmt{body, expr.NewExpressionStmt(increment)})
+		incrementStmt := expr.NewExpressionStmt(increment)
+		incrementStmt.SetLine(incrementLine)
+		body = expr.NewBlockStmt([]expr.Stmt{body, incrementStmt})
+		body.SetLine(line)
 	}
 
 	if cond == nil {
 		cond = expr.NewLiteral(true)
 	}
 	body = expr.NewWhileStmt(cond, body)
+	body.SetLine(line)
 
 	if initializer != nil {
 		body = expr.NewBlockStmt([]expr.Stmt{initializer, body})
+		body.SetLine(line)
 	}
 
 	return body
 }
 
+// checkIn in 不是关键字, 只有 for (var name in ...) 中的 in 表示 for-in 循环
+func (p *Parser) checkIn() bool {
+	if !p.check(token.Var) || p.tokens[p.current+1].TokenType != token.Identifier {
+		return false
+	}
+	in := p.tokens[p.current+2]
+	return in.TokenType == token.Identifier && in.Lexeme == "in"
+}
+
+func (p *Parser) forInStatement() expr.Stmt {
+	p.advance()
+	name := p.advance()
+	in := p.advance()
+
+	iterable := p.expression()
+	p.consume(token.RightParen, "Expect ')' after for-in clause.")
+
+	return expr.NewForInStmt(name, in, iterable, p.statement())
+}
+
 func (p *Parser) ifStatement() expr.Stmt {
-	p.consume(token.LeftParen, `expect "(" after 'if'`)
+	p.consume(token.LeftParen, "Expect '(' after 'if'.")
 	cond := p.expression()
-	p.consume(token.RightParen, `expect ")" after if condition `)
+	p.consume(token.RightParen, "Expect ')' after if condition.")
 
 	thenBranch := p.statement()
 	var elseBranch expr.Stmt
@@ -163,68 +339,422 @@ func (p *Parser) ifStatement() expr.Stmt {
 
 func (p *Parser) printStatement() *expr.Print {
 	value := p.expression()
-	p.consume(token.Semicolon, `Expect ":" after value.`)
+	p.consume(token.Semicolon, "Expect ';' after value.")
 	return expr.NewPrintStmt(value)
 }
 
+func (p *Parser) assertStatement() *expr.Assert {
+	keyword := p.previous()
+	cond := p.expression()
+
+	var message expr.Expr
+	if p.match(token.Comma) {
+		message = p.expression()
+	}
+	p.consume(token.Semicolon, "Expect ';' after assertion.")
+
+	return expr.NewAssertStmt(keyword, cond, message)
+}
+
 func (p *Parser) returnStatement() *expr.Return {
 	keyword := p.previous()
+	if p.inTest {
+		p.error(keyword, "Cannot return from a test.")
+	} else if len(p.generators) == 0 {
+		p.error(keyword, "Cannot return from top-level code.")
+	}
 	var value expr.Expr
 
 	if !p.check(token.Semicolon) {
 		value = p.expression()
 	}
-	p.consume(token.Semicolon, "Expect `;` after return value.")
+	p.consume(token.Semicolon, "Expect ';' after return value.")
 
 	return expr.NewReturnStmt(keyword, value)
 }
 
+func (p *Parser) yieldStatement() *expr.Yield {
+	keyword := p.previous()
+	if n := len(p.generators); n > 0 {
+		p.generators[n-1] = true
+	} else {
+		p.error(keyword, "Cannot yield outside of a function.")
+	}
+
+	var value expr.Expr
+	if !p.check(token.Semicolon) {
+		value = p.expression()
+	}
+	p.consume(token.Semicolon, "Expect ';' after yield value.")
+
+	return expr.NewYieldStmt(keyword, value)
+}
+
 func (p *Parser) whileStatement() expr.Stmt {
-	p.consume(token.LeftParen, `expect "(" after 'while'.`)
+	p.consume(token.LeftParen, "Expect '(' after 'while'.")
 	cond := p.expression()
-	p.consume(token.RightParen, `expect ")" after condition.`)
+	p.consume(token.RightParen, "Expect ')' after condition.")
 
 	body := p.statement()
 
 	return expr.NewWhileStmt(cond, body)
 }
 
+func (p *Parser) matchStatement() expr.Stmt {
+	keyword := p.previous()
+	p.consume(token.LeftParen, "Expect '(' after 'match'.")
+	value := p.expression()
+	p.consume(token.RightParen, "Expect ')' after match value.")
+	p.consume(token.LeftBrace, "Expect '{' before match cases.")
+
+	var cases []*expr.Case
+	for !p.check(token.RightBrace) && !p.isAtEnd() {
+		cases = append(cases, p.matchCase())
+	}
+	p.consume(token.RightBrace, "Expect '}' after match cases.")
+	p.checkCases(cases)
+
+	return expr.NewMatchStmt(keyword, value, cases)
+}
+
+// checkCases 对永远不会匹配的 case 给出警告: 前面有没有 guard 的 _ 或变量模式,
+// 或者所有模式都是前面没有 guard 的 case 中已经出现过的字面量
+func (p *Parser) checkCases(cases []*expr.Case) {
+	seen := make(map[string]bool)
+	exhaustive := false
+
+	for _, c := range cases {
+		covered := exhaustive
+		if !covered {
+			covered = true
+			for _, pattern := range c.Patterns {
+				if key, ok := literalKey(pattern); !ok || !seen[key] {
+					covered = false
+				}
+			}
+		}
+		if covered {
+			p.warn(c.Keyword, "Case can never match.")
+			continue
+		}
+
+		if c.Guard != nil {
+			continue
+		}
+		for _, pattern := range c.Patterns {
+			switch pattern.(type) {
+			case *expr.WildcardPattern, *expr.BindingPattern:
+				exhaustive = true
+			}
+			if key, ok := literalKey(pattern); ok {
+				seen[key] = true
+			}
+		}
+	}
+}
+
+// literalKey 字面量模式的类型和值, 用于找出重复的模式; 运行时 match 认为相等的模式的 key 相同,
+// 数字与 numbersEqual 一样按照精确的值比较, 所以 1, 1.0, 1n 和 1m 是同一个 key
+func literalKey(pattern expr.Pattern) (string, bool) {
+	p, ok := pattern.(*expr.LiteralPattern)
+	if !ok {
+		return "", false
+	}
+
+	switch v := p.Value.(type) {
+	case *expr.Literal:
+		if r, ok := exactValue(v.Value); ok {
+			return "number " + r.RatString(), true
+		}
+		return fmt.Sprintf("%T %v", v.Value, v.Value), true
+	case *expr.Unary:
+		if lit, ok := v.Right.(*expr.Literal); ok {
+			if r, ok := exactValue(lit.Value); ok {
+				return "number " + new(big.Rat).Neg(r).RatString(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// exactValue 数字字面量精确的有理数值
+func exactValue(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case *big.Int:
+		return new(big.Rat).SetInt(n), true
+	case *big.Rat:
+		return n, true
+	case float64:
+		r := new(big.Rat).SetFloat64(n)
+		return r, r != nil
+	}
+	return nil, false
+}
+
+func (p *Parser) matchCase() *expr.Case {
+	keyword := p.consume(token.Case, "Expect 'case'.")
+
+	patterns := []expr.Pattern{p.pattern()}
+	for p.match(token.Comma) {
+		patterns = append(patterns, p.pattern())
+	}
+
+	var guard expr.Expr
+	if p.match(token.If) {
+		guard = p.expression()
+	}
+	p.consume(token.Arrow, "Expect '=>' after case pattern.")
+
+	return expr.NewCase(keyword, patterns, guard, p.statement())
+}
+
+// pattern 字面量, _, 变量名或者列表模式
+func (p *Parser) pattern() expr.Pattern {
+	defer p.nest()()
+
+	switch {
+	case p.match(token.False):
+		return &expr.LiteralPattern{Value: expr.NewLiteral(false)}
+	case p.match(token.True):
+		return &expr.LiteralPattern{Value: expr.NewLiteral(true)}
+	case p.match(token.Nil):
+		return &expr.LiteralPattern{Value: expr.NewLiteral(nil)}
+	case p.match(token.Number):
+		return &expr.LiteralPattern{Value: p.numberLiteral()}
+	case p.match(token.String):
+		return &expr.LiteralPattern{Value: expr.NewLiteral(p.previous().Literal)}
+	case p.match(token.Minus):
+		op := p.previous()
+		number := p.consume(token.Number, "Expect number after '-' in pattern.")
+		if _, ok := number.Literal.(scanner.MinInt64Magnitude); ok {
+			return &expr.LiteralPattern{Value: expr.NewLiteral(int64(math.MinInt64))}
+		}
+		return &expr.LiteralPattern{Value: expr.NewUnary(op, expr.NewLiteral(number.Literal))}
+	case p.match(token.Identifier):
+		name := p.previous()
+		if name.Lexeme == "_" {
+			return &expr.WildcardPattern{Token: name}
+		}
+		return &expr.BindingPattern{Name: name}
+	case p.match(token.LeftBracket):
+		bracket := p.previous()
+		var elements []expr.Pattern
+		if !p.check(token.RightBracket) {
+			elements = append(elements, p.pattern())
+			for p.match(token.Comma) {
+				elements = append(elements, p.pattern())
+			}
+		}
+		p.consume(token.RightBracket, "Expect ']' after list pattern.")
+		return &expr.ListPattern{Bracket: bracket, Elements: elements}
+	case p.match(token.LeftBrace):
+		return p.shapePattern()
+	}
+	panic(p.error(p.peek(), "Expect pattern."))
+}
+
+func (p *Parser) shapePattern() expr.Pattern {
+	shape := &expr.ShapePattern{Brace: p.previous()}
+	if !p.check(token.RightBrace) {
+		for {
+			name := p.consume(token.Identifier, "Expect property name in shape pattern.")
+			var field expr.Pattern = &expr.BindingPattern{Name: name}
+			if p.match(token.Colon) {
+				field = p.pattern()
+			} else if name.Lexeme == "_" {
+				field = &expr.WildcardPattern{Token: name}
+			}
+			shape.Names = append(shape.Names, name)
+			shape.Fields = append(shape.Fields, field)
+			if !p.match(token.Comma) {
+				break
+			}
+		}
+	}
+	p.consume(token.RightBrace, "Expect '}' after shape pattern.")
+	return shape
+}
+
+func (p *Parser) spawnStatement() expr.Stmt {
+	keyword := p.previous()
+	call, ok := p.expression().(*expr.Call)
+	if !ok {
+		panic(p.error(keyword, "Expect function call after 'spawn'."))
+	}
+	p.consume(token.Semicolon, "Expect ';' after spawn.")
+
+	return expr.NewSpawnStmt(keyword, call)
+}
+
+func (p *Parser) selectStatement() expr.Stmt {
+	keyword := p.previous()
+	p.consume(token.LeftBrace, "Expect '{' after 'select'.")
+
+	var cases []*expr.SelectCase
+	hasDefault := false
+	for !p.check(token.RightBrace) && !p.isAtEnd() {
+		c := p.selectCase()
+		if c.Operation == nil {
+			if hasDefault {
+				p.error(c.Keyword, "Cannot have more than one default case.")
+			}
+			hasDefault = true
+		}
+		cases = append(cases, c)
+	}
+	p.consume(token.RightBrace, "Expect '}' after select cases.")
+
+	return expr.NewSelectStmt(keyword, cases)
+}
+
+// selectCase case _, case ch.send(value), case ch.recv() 或者 case var name = ch.recv()
+func (p *Parser) selectCase() *expr.SelectCase {
+	c := &expr.SelectCase{Keyword: p.consume(token.Case, "Expect 'case'.")}
+
+	if p.check(token.Identifier) && p.peek().Lexeme == "_" && p.tokens[p.current+1].TokenType == token.Arrow {
+		p.advance()
+	} else {
+		if p.match(token.Var) {
+			c.Name = p.consume(token.Identifier, "Expect variable name.")
+			p.consume(token.Equal, "Expect '=' after variable name.")
+		}
+
+		call, ok := p.call().(*expr.Call)
+		var get *expr.Get
+		if ok {
+			get, ok = call.Callee.(*expr.Get)
+		}
+		switch {
+		case ok && get.Name.Lexeme == "recv" && len(call.Arguments) == 0:
+		case ok && get.Name.Lexeme == "send" && len(call.Arguments) == 1 && c.Name == nil:
+			c.Value = call.Arguments[0]
+		default:
+			panic(p.error(c.Keyword, "Expect channel operation."))
+		}
+		c.Channel, c.Operation = get.Object, get.Name
+	}
+
+	p.consume(token.Arrow, "Expect '=>' after select case.")
+	c.Body = p.statement()
+	return c
+}
+
 func (p *Parser) expressionStatement() *expr.Expression {
 	value := p.expression()
-	p.consume(token.Semicolon, `Expect ":" after value.`)
+	p.consume(token.Semicolon, "Expect ';' after expression.")
 	return expr.NewExpressionStmt(value)
 }
 
 func (p *Parser) block() []expr.Stmt {
+	defer p.nest()()
+
 	statements := make([]expr.Stmt, 0)
 
 	for !p.check(token.RightBrace) && !p.isAtEnd() {
-		statements = append(statements, p.declaration())
+		if stmt := p.declaration(); stmt != nil {
+			statements = append(statements, stmt)
+		}
 	}
 
-	p.consume(token.RightBrace, `Expect "}" after block!`)
+	p.consume(token.RightBrace, "Expect '}' after block.")
 	return statements
 }
 
+// ParseExpression 解析单个表达式, 例如调试器中求值的表达式
+func (p *Parser) ParseExpression() (exp expr.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			parseErr, ok := r.(*ParseError)
+			if !ok {
+				panic(r)
+			}
+			exp, err = nil, parseErr
+		}
+	}()
+
+	exp = p.expression()
+	if !p.isAtEnd() {
+		return nil, p.error(p.peek(), "Expect end of expression.")
+	}
+	return exp, nil
+}
+
 func (p *Parser) expression() expr.Expr {
 	//return p.equality()
 	return p.assignment()
 }
 
 func (p *Parser) assignment() expr.Expr {
-	exp := p.or()
+	defer p.nest()()
+
+	exp := p.conditional()
 	//exp := p.equality()
 
-	if p.match(token.Equal) {
+	if p.match(token.Equal, token.PlusEqual, token.MinusEqual, token.StarEqual, token.SlashEqual, token.PercentEqual) {
 		equals := p.previous()
 		value := p.assignment()
 
 		if v, ok := exp.(*expr.Variable); ok {
 			name := v.Name
+			if op, ok := compoundOperators[equals.TokenType]; ok {
+				// a += b 转换为 a = a + b, 变量只会求值一次
+				operator := &token.Token{TokenType: op, Lexeme: equals.Lexeme, Line: equals.Line, Column: equals.Column}
+				value = expr.NewBinary(v, value, operator)
+			}
 			return expr.NewAssign(name, value)
 		}
+		if get, ok := exp.(*expr.Get); ok {
+			set := expr.NewSet(get.Object, get.Name, value)
+			if op, ok := compoundOperators[equals.TokenType]; ok {
+				set.Operator = &token.Token{TokenType: op, Lexeme: equals.Lexeme, Line: equals.Line, Column: equals.Column}
+			}
+			return set
+		}
+
+		p.error(equals, "Invalid assignment target.")
+	}
+
+	return exp
+}
+
+// compoundOperators 复合赋值对应的二元运算符
+var compoundOperators = map[token.Type]token.Type{
+	token.PlusEqual:    token.Plus,
+	token.MinusEqual:   token.Minus,
+	token.StarEqual:    token.Star,
+	token.SlashEqual:   token.Slash,
+	token.PercentEqual: token.Percent,
+}
+
+// conditional 三元表达式, 右结合: a ? b : c ? d : e
+func (p *Parser) conditional() expr.Expr {
+	exp := p.coalesce()
+
+	if p.match(token.Question) {
+		defer p.nest()()
+
+		question := p.previous()
+		thenBranch := p.expression()
+		p.consume(token.Colon, "Expect ':' after then branch of conditional expression.")
+		elseBranch := p.conditional()
+
+		exp = expr.NewConditional(exp, thenBranch, elseBranch, question)
+	}
+
+	return exp
+}
+
+// coalesce a ?? b, 只有 a 为 nil 时才对 b 求值
+func (p *Parser) coalesce() expr.Expr {
+	exp := p.or()
 
-		lox.TokenError(equals, "Invalid Assignment target.")
+	for p.match(token.QuestionQuestion) {
+		op := p.previous()
+		right := p.or()
+
+		exp = expr.NewLogical(exp, right, op)
 	}
 
 	return exp
@@ -268,9 +798,55 @@ func (p *Parser) equality() expr.Expr {
 }
 
 func (p *Parser) comparison() expr.Expr {
-	exp := p.term()
+	exp := p.bitOr()
 
 	for p.match(token.Greater, token.GreaterEqual, token.Less, token.LessEqual) {
+		op := p.previous()
+		right := p.bitOr()
+		exp = expr.NewBinary(exp, right, op)
+	}
+	return exp
+}
+
+// 位运算的优先级与 Python 相同, 高于比较运算, 低于加减
+
+func (p *Parser) bitOr() expr.Expr {
+	exp := p.bitXor()
+
+	for p.match(token.Pipe) {
+		op := p.previous()
+		right := p.bitXor()
+		exp = expr.NewBinary(exp, right, op)
+	}
+	return exp
+}
+
+func (p *Parser) bitXor() expr.Expr {
+	exp := p.bitAnd()
+
+	for p.match(token.Caret) {
+		op := p.previous()
+		right := p.bitAnd()
+		exp = expr.NewBinary(exp, right, op)
+	}
+	return exp
+}
+
+func (p *Parser) bitAnd() expr.Expr {
+	exp := p.shift()
+
+	for p.match(token.Ampersand) {
+		op := p.previous()
+		right := p.shift()
+		exp = expr.NewBinary(exp, right, op)
+	}
+	return exp
+}
+
+func (p *Parser) shift() expr.Expr {
+	exp := p.term()
+
+	for p.match(token.LessLess, token.GreaterGreater) {
 		op := p.previous()
 		right := p.term()
 		exp = expr.NewBinary(exp, right, op)
@@ -293,7 +869,7 @@ func (p *Parser) term() expr.Expr {
 func (p *Parser) factor() expr.Expr {
 	exp := p.unary()
 
-	for p.match(token.Slash, token.Star) {
+	for p.match(token.Slash, token.Star, token.TildeSlash, token.Percent) {
 		op := p.previous()
 		right := p.unary()
 		exp = expr.NewBinary(exp, right, op)
@@ -303,13 +879,61 @@ func (p *Parser) factor() expr.Expr {
 }
 
 func (p *Parser) unary() expr.Expr {
-	if p.match(token.Bang, token.Minus) {
+	if p.match(token.PlusPlus, token.MinusMinus) {
+		defer p.nest()()
+
+		op := p.previous()
+		return p.update(op, p.unary(), true)
+	}
+
+	if p.match(token.Bang, token.Minus, token.Tilde) {
+		defer p.nest()()
+
 		op := p.previous()
+		if op.TokenType == token.Minus && p.checkMinInt64() {
+			p.advance()
+			return expr.NewLiteral(int64(math.MinInt64))
+		}
 		right := p.unary()
 		return expr.NewUnary(op, right)
 	}
 
-	return p.call()
+	return p.power()
+}
+
+// checkMinInt64 负号后面是 9223372036854775808 并且它不是调用, 属性, ++/-- 或者 ** 的操作数,
+// 这时 -9223372036854775808 作为一个 int64 字面量
+func (p *Parser) checkMinInt64() bool {
+	if _, ok := p.peek().Literal.(scanner.MinInt64Magnitude); !ok || !p.check(token.Number) {
+		return false
+	}
+	switch p.tokens[p.current+1].TokenType {
+	case token.LeftParen, token.Dot, token.PlusPlus, token.MinusMinus, token.StarStar:
+		return false
+	}
+	return true
+}
+
+// numberLiteral 刚刚匹配的数字字面量, 不能折叠的 9223372036854775808 报告超出范围
+func (p *Parser) numberLiteral() expr.Expr {
+	tk := p.previous()
+	if _, ok := tk.Literal.(scanner.MinInt64Magnitude); ok {
+		p.error(tk, "Integer literal out of range.")
+		return expr.NewLiteral(int64(0))
+	}
+	return expr.NewLiteral(tk.Literal)
+}
+
+// power ** 右结合, 比左边的一元运算符优先级高: -2 ** 2 为 -4
+func (p *Parser) power() expr.Expr {
+	exp := p.call()
+
+	if p.match(token.StarStar) {
+		op := p.previous()
+		right := p.unary()
+		exp = expr.NewBinary(exp, right, op)
+	}
+	return exp
 }
 
 func (p *Parser) call() expr.Expr {
@@ -318,30 +942,49 @@ func (p *Parser) call() expr.Expr {
 	for {
 		if p.match(token.LeftParen) {
 			exp = p.finishCall(exp)
+		} else if p.match(token.Dot) {
+			name := p.consume(token.Identifier, "Expect property name after '.'.")
+			exp = expr.NewGet(exp, name)
 		} else {
 			break
 		}
 	}
 
+	if p.match(token.PlusPlus, token.MinusMinus) {
+		return p.update(p.previous(), exp, false)
+	}
 	return exp
 }
 
+// update ++ 和 -- 的操作数只能是变量或者属性
+func (p *Parser) update(op *token.Token, target expr.Expr, prefix bool) expr.Expr {
+	switch t := target.(type) {
+	case *expr.Variable:
+		return expr.NewUpdate(t.Name, op, prefix)
+	case *expr.Get:
+		u := expr.NewUpdate(t.Name, op, prefix)
+		u.Object = t.Object
+		return u
+	}
+	p.error(op, "Invalid increment target: expect a variable or a property.")
+	return target
+}
+
 func (p *Parser) finishCall(callee expr.Expr) expr.Expr {
 	var arguments []expr.Expr
 
 	if !p.check(token.RightParen) {
-		if len(arguments) > 8 {
-			p.errFunc(p.peek(), "Cannot have more than 8 arguments")
-		}
-
 		arguments = append(arguments, p.expression())
 
 		for p.match(token.Comma) {
+			if len(arguments) >= 8 {
+				p.error(p.peek(), "Cannot have more than 8 arguments.")
+			}
 			arguments = append(arguments, p.expression())
 		}
 	}
 
-	paren := p.consume(token.RightParen, "Expect `)` after arguments!")
+	paren := p.consume(token.RightParen, "Expect ')' after arguments.")
 	return expr.NewCall(callee, paren, arguments)
 }
 
@@ -358,27 +1001,93 @@ func (p *Parser) primary() expr.Expr {
 		return expr.NewLiteral(nil)
 	}
 
-	if p.match(token.Number, token.String) {
+	if p.match(token.Number) {
+		return p.numberLiteral()
+	}
+
+	if p.match(token.String) {
 		return expr.NewLiteral(p.previous().Literal)
 	}
 
+	if p.match(token.Interpolation) {
+		return p.interpolation()
+	}
+
 	if p.match(token.Identifier) {
 		return expr.NewVariable(p.previous())
 	}
 
 	if p.match(token.LeftParen) {
 		exp := p.expression()
-		p.consume(token.RightParen, "Expect ')' after expression")
+		p.consume(token.RightParen, "Expect ')' after expression.")
 		return expr.NewGrouping(exp)
 	}
-	panic(fmt.Sprintf("expect expression: %s", p.peek().Lexeme))
+
+	if p.match(token.LeftBracket) {
+		return p.list()
+	}
+
+	if p.match(token.LeftBrace) {
+		return p.mapLiteral()
+	}
+	panic(p.error(p.peek(), "Expect expression."))
+}
+
+// mapLiteral {key: value, ...}, 可以有结尾的逗号
+func (p *Parser) mapLiteral() expr.Expr {
+	brace := p.previous()
+
+	var keys, values []expr.Expr
+	for !p.check(token.RightBrace) && !p.isAtEnd() {
+		keys = append(keys, p.expression())
+		p.consume(token.Colon, "Expect ':' after map key.")
+		values = append(values, p.expression())
+		if !p.match(token.Comma) {
+			break
+		}
+	}
+	p.consume(token.RightBrace, "Expect '}' after map entries.")
+
+	return expr.NewMap(brace, keys, values)
+}
+
+func (p *Parser) list() expr.Expr {
+	bracket := p.previous()
+
+	var elements []expr.Expr
+	if !p.check(token.RightBracket) {
+		elements = append(elements, p.expression())
+		for p.match(token.Comma) {
+			elements = append(elements, p.expression())
+		}
+	}
+	p.consume(token.RightBracket, "Expect ']' after list elements.")
+
+	return expr.NewList(bracket, elements)
+}
+
+// interpolation "a${x}b${y}c" 的 token 为 Interpolation(a) x Interpolation(b) y String(c)
+func (p *Parser) interpolation() expr.Expr {
+	parts := []expr.Expr{expr.NewLiteral(p.previous().Literal)}
+
+	for {
+		parts = append(parts, p.expression())
+
+		if p.match(token.Interpolation) {
+			parts = append(parts, expr.NewLiteral(p.previous().Literal))
+			continue
+		}
+		p.consume(token.String, "Expect '}' after interpolated expression.")
+		parts = append(parts, expr.NewLiteral(p.previous().Literal))
+		return expr.NewInterpolation(parts)
+	}
 }
 
 func (p *Parser) consume(t token.Type, msg string) *token.Token {
 	if p.check(t) {
 		return p.advance()
 	}
-	panic(msg)
+	panic(p.error(p.peek(), msg))
 }
 
 func (p *Parser) match(tokenTypes ...token.Type) bool {
@@ -417,6 +1126,45 @@ func (p *Parser) previous() *token.Token {
 	return p.tokens[p.current-1]
 }
 
-func (p *Parser) error() {
+// nest 进入一层嵌套, 返回离开时调用的函数
+func (p *Parser) nest() func() {
+	p.depth++
+	if p.depth > MaxNesting {
+		p.depth--
+		panic(p.error(p.peek(), "Too much nesting."))
+	}
+	return func() {
+		p.depth--
+	}
+}
+
+func (p *Parser) error(tk *token.Token, msg string) *ParseError {
+	p.hadError = true
+	p.errFunc(tk, msg)
+	return &ParseError{Tk: tk, Msg: msg}
+}
+
+func (p *Parser) warn(tk *token.Token, msg string) {
+	if p.warnFunc != nil {
+		p.warnFunc(tk, msg)
+	}
+}
+
+// synchronize 丢弃 token 直到下一条语句的开头
+func (p *Parser) synchronize() {
+	p.advance()
 
+	for !p.isAtEnd() {
+		if p.previous().TokenType == token.Semicolon {
+			return
+		}
+
+		switch p.peek().TokenType {
+		case token.Class, token.Fun, token.Var, token.For, token.If, token.While, token.Print, token.Return, token.Assert,
+			token.Match, token.Yield, token.Spawn, token.Select:
+			return
+		}
+
+		p.advance()
+	}
 }
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// parse 返回语句和所有的错误, 警告
+func parse(t *testing.T, source string) ([]expr.Stmt, []string, []string) {
+	t.Helper()
+
+	var errs, warnings []string
+	s := scanner.NewScanner(source)
+	s.SetErrFunc(func(line, column int, msg string) {
+		t.Fatalf("scan error on line %d: %s", line, msg)
+	})
+	p := NewParser(s.ScanTokens(), func(tk *token.Token, msg string) {
+		errs = append(errs, tk.Lexeme+": "+msg)
+	})
+	p.SetWarnFunc(func(tk *token.Token, msg string) {
+		warnings = append(warnings, tk.Lexeme+": "+msg)
+	})
+	return p.Parse(), errs, warnings
+}
+
+func TestUnreachableCaseWarning(t *testing.T) {
+	tests := []struct {
+		source   string
+		warnings []string
+	}{
+		{`match (1) { case 1 => print 1; case 2 => print 2; }`, nil},
+		{`match (1) { case 1 => print 1; case 1 => print 2; }`, []string{"case: Case can never match."}},
+		{`match (1) { case -1 => print 1; case 1, -1 => print 2; }`, nil},
+		{`match (1) { case 1 if false => print 1; case 1 => print 2; }`, nil},
+		{`match (1) { case x => print x; case _ => print 2; case 3 => print 3; }`, []string{"case: Case can never match.", "case: Case can never match."}},
+		{`match (1) { case x if x > 0 => print x; case _ => print 2; }`, nil},
+		{`match (1) { case 1 => print 1; case 1.0 => print 2; }`, []string{"case: Case can never match."}},
+		{`match (1) { case 1n => print 1; case 2, 1 => print 2; case 2m, 1.0 => print 3; }`, []string{"case: Case can never match."}},
+		{`match (1) { case -0.5 => print 1; case -0.5m => print 2; }`, []string{"case: Case can never match."}},
+		{`match (1) { case 0.1 => print 1; case 0.1m => print 2; case "1" => print 3; case 1 => print 4; }`, nil},
+		{`match (1) { case [_] => print 1; case [_] => print 2; }`, nil},
+		{`match (1) { case {x} => print x; case _ => print 2; }`, nil},
+	}
+
+	for _, tt := range tests {
+		_, errs, warnings := parse(t, tt.source)
+		if len(errs) > 0 {
+			t.Errorf("%s: unexpected errors %v", tt.source, errs)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%s: got warnings %v, want %v", tt.source, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestShapePattern(t *testing.T) {
+	statements, errs, _ := parse(t, `match (p) { case {x, y: 0, z: [_]} => print x; }`)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	shape, ok := statements[0].(*expr.Match).Cases[0].Patterns[0].(*expr.ShapePattern)
+	if !ok {
+		t.Fatalf("got %T, want *expr.ShapePattern", statements[0].(*expr.Match).Cases[0].Patterns[0])
+	}
+	var names []string
+	for _, name := range shape.Names {
+		names = append(names, name.Lexeme)
+	}
+	if !reflect.DeepEqual(names, []string{"x", "y", "z"}) {
+		t.Errorf("got names %v", names)
+	}
+	if _, ok := shape.Fields[0].(*expr.BindingPattern); !ok {
+		t.Errorf("shorthand {x}: got %T, want *expr.BindingPattern", shape.Fields[0])
+	}
+	if _, ok := shape.Fields[1].(*expr.LiteralPattern); !ok {
+		t.Errorf("y: 0: got %T, want *expr.LiteralPattern", shape.Fields[1])
+	}
+	if _, ok := shape.Fields[2].(*expr.ListPattern); !ok {
+		t.Errorf("z: [_]: got %T, want *expr.ListPattern", shape.Fields[2])
+	}
+}
+
+func TestTestDeclarations(t *testing.T) {
+	tests := []struct {
+		source string
+		errs   []string
+	}{
+		{`test "top" { assert true; }`, nil},
+		{`test "helper" { fun f() { return 1; } assert f() == 1; }`, nil},
+		{`{ test "block" {} }`, []string{"test: Test declarations must be at the top level."}},
+		{`fun f() { test "function" {} }`, []string{"test: Test declarations must be at the top level."}},
+		{`test "return" { return; }`, []string{"return: Cannot return from a test."}},
+		{`test "nested return" { if (true) { return 1; } }`, []string{"return: Cannot return from a test."}},
+		{`var test = 1; { print test; }`, nil},
+	}
+
+	for _, tt := range tests {
+		_, errs, _ := parse(t, tt.source)
+		if !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("%s: got errors %v, want %v", tt.source, errs, tt.errs)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WritePprof 写出 gzip 压缩的 profile.proto, 可以用 go tool pprof 查看
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+//
+// 每个样本是一个调用栈, 值为执行的语句数和时间(纳秒)
+func (p *Profiler) WritePprof(w io.Writer, filename string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := &pprofBuilder{strings: map[string]int64{"": 0}, strs: []string{""}}
+	b.functions = make(map[string]uint64)
+	b.locations = make(map[frame]uint64)
+
+	var prof protoBuf
+	prof.message(1, b.valueType("statements", "count"))
+	prof.message(1, b.valueType("time", "nanoseconds"))
+
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := p.samples[k]
+		var ids []uint64
+		for _, f := range s.stack {
+			ids = append(ids, b.location(f, filename))
+		}
+
+		var sample protoBuf
+		sample.packedUint64s(1, ids)
+		sample.packedInt64s(2, []int64{s.count, s.nanos})
+		prof.message(2, &sample)
+	}
+
+	for _, loc := range b.locs {
+		prof.message(4, loc)
+	}
+	for _, fn := range b.funcs {
+		prof.message(5, fn)
+	}
+	if !p.start.IsZero() {
+		prof.int64(9, p.start.UnixNano())
+		prof.int64(10, int64(p.last.Sub(p.start)))
+	}
+	prof.message(11, b.valueType("time", "nanoseconds"))
+	prof.int64(14, b.str("time"))
+
+	// 字符串表必须最后写出
+	for _, s := range b.strs {
+		prof.string(6, s)
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(prof.bytes); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type pprofBuilder struct {
+	strings   map[string]int64
+	strs      []string
+	functions map[string]uint64
+	funcs     []*protoBuf
+	locations map[frame]uint64
+	locs      []*protoBuf
+}
+
+func (b *pprofBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	i := int64(len(b.strs))
+	b.strings[s] = i
+	b.strs = append(b.strs, s)
+	return i
+}
+
+func (b *pprofBuilder) valueType(typ, unit string) *protoBuf {
+	var vt protoBuf
+	vt.int64(1, b.str(typ))
+	vt.int64(2, b.str(unit))
+	return &vt
+}
+
+func (b *pprofBuilder) function(name, filename string) uint64 {
+	if id, ok := b.functions[name]; ok {
+		return id
+	}
+
+	id := uint64(len(b.funcs) + 1)
+	b.functions[name] = id
+
+	var fn protoBuf
+	fn.uint64(1, id)
+	// pprof 显示时会去掉 <...>, 顶层脚本 <script> 改为 script
+	fn.int64(2, b.str(strings.Trim(name, "<>")))
+	fn.int64(3, b.str(name))
+	fn.int64(4, b.str(filename))
+	b.funcs = append(b.funcs, &fn)
+	return id
+}
+
+func (b *pprofBuilder) location(f frame, filename string) uint64 {
+	if id, ok := b.locations[f]; ok {
+		return id
+	}
+
+	id := uint64(len(b.locs) + 1)
+	b.locations[f] = id
+
+	var line protoBuf
+	line.uint64(1, b.function(f.name, filename))
+	line.int64(2, int64(f.line))
+
+	var loc protoBuf
+	loc.uint64(1, id)
+	loc.message(4, &line)
+	b.locs = append(b.locs, &loc)
+	return id
+}
+
+// protoBuf 最小的 protobuf 编码器, 只支持 profile.proto 用到的类型
+type protoBuf struct {
+	bytes []byte
+}
+
+func (pb *protoBuf) varint(x uint64) {
+	for x >= 0x80 {
+		pb.bytes = append(pb.bytes, byte(x)|0x80)
+		x >>= 7
+	}
+	pb.bytes = append(pb.bytes, byte(x))
+}
+
+func (pb *protoBuf) key(field, wireType int) {
+	pb.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (pb *protoBuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	pb.key(field, 0)
+	pb.varint(x)
+}
+
+func (pb *protoBuf) int64(field int, x int64) {
+	pb.uint64(field, uint64(x))
+}
+
+func (pb *protoBuf) string(field int, s string) {
+	pb.key(field, 2)
+	pb.varint(uint64(len(s)))
+	pb.bytes = append(pb.bytes, s...)
+}
+
+func (pb *protoBuf) message(field int, m *protoBuf) {
+	pb.key(field, 2)
+	pb.varint(uint64(len(m.bytes)))
+	pb.bytes = append(pb.bytes, m.bytes...)
+}
+
+func (pb *protoBuf) packedUint64s(field int, xs []uint64) {
+	var packed protoBuf
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	pb.message(field, &packed)
+}
+
+func (pb *protoBuf) packedInt64s(field int, xs []int64) {
+	var packed protoBuf
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	pb.message(field, &packed)
+}
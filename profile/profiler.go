@@ -0,0 +1,268 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+// FuncStat 一个 Lox 函数的统计
+type FuncStat struct {
+	Name  string
+	Calls int
+	Cum   time.Duration // 包含被调用函数的时间, 递归调用只计算最外层
+	Self  time.Duration
+}
+
+// LineStat 一行源码的统计
+type LineStat struct {
+	Line  int
+	Count int           // 执行的语句数
+	Time  time.Duration // 不含子语句和被调用函数的时间
+}
+
+// frame 调用栈中一帧的函数名和当前行
+type frame struct {
+	name string
+	line int
+}
+
+type activeCall struct {
+	name     string
+	start    time.Time
+	children time.Duration
+}
+
+// sample 一个调用栈上累计的语句数和时间, 用于生成 pprof
+type sample struct {
+	stack []frame // 最内层在最前
+	count int64
+	nanos int64
+}
+
+// taskState 一个任务的调用栈和计时; 生成器与调用 next() 的任务交替执行, 共享它的状态
+type taskState struct {
+	last    time.Time
+	calls   []*activeCall
+	current []frame // 正在计时的调用栈, 最内层在最前
+}
+
+// Profiler 统计每个函数和每一行的执行次数与时间
+//
+// spawn 出的任务会在不同的 goroutine 中同时调用钩子, 所以由 mu 保护; 每个任务分别记录调用栈和计时,
+// 并发任务的时间会重叠
+type Profiler struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	start   time.Time
+	last    time.Time
+	funcs   map[string]*FuncStat
+	lines   map[int]*LineStat
+	tasks   map[*interpreter.Task]*taskState
+	samples map[string]*sample
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{
+		now:     time.Now,
+		funcs:   make(map[string]*FuncStat),
+		lines:   make(map[int]*LineStat),
+		tasks:   make(map[*interpreter.Task]*taskState),
+		samples: make(map[string]*sample),
+	}
+}
+
+// BeforeExecute 实现 interpreter.Hook
+func (p *Profiler) BeforeExecute(itp *interpreter.Interpreter, stmt expr.Stmt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.charge(itp.Task())
+	if _, ok := stmt.(*expr.Block); ok {
+		return
+	}
+
+	t.current = stackOf(itp.Frames())
+	line := p.lineStat(stmt.Line())
+	line.Count++
+	p.sampleOf(t.current).count++
+}
+
+// EnterFunction 实现 interpreter.CallHook
+func (p *Profiler) EnterFunction(itp *interpreter.Interpreter, fn *interpreter.Function) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.charge(itp.Task())
+	stat, ok := p.funcs[fn.Name()]
+	if !ok {
+		stat = &FuncStat{Name: fn.Name()}
+		p.funcs[fn.Name()] = stat
+	}
+	stat.Calls++
+
+	t.calls = append(t.calls, &activeCall{name: fn.Name(), start: t.last})
+}
+
+// ExitFunction 实现 interpreter.CallHook
+func (p *Profiler) ExitFunction(itp *interpreter.Interpreter, fn *interpreter.Function) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.charge(itp.Task())
+	call := t.calls[len(t.calls)-1]
+	t.calls = t.calls[:len(t.calls)-1]
+
+	elapsed := t.last.Sub(call.start)
+	stat := p.funcs[call.name]
+	stat.Self += elapsed - call.children
+	if !t.active(call.name) {
+		stat.Cum += elapsed
+	}
+	if len(t.calls) > 0 {
+		t.calls[len(t.calls)-1].children += elapsed
+	}
+
+	// 回到调用方继续对表达式求值; spawn 出的任务调用的函数返回后任务就结束了
+	frames := itp.Frames()
+	t.current = stackOf(frames[:len(frames)-1])
+	if len(t.calls) == 0 && itp.Task().Spawned() {
+		delete(p.tasks, itp.Task())
+	}
+}
+
+// Stop 结束计时, 在程序执行完之后调用
+func (p *Profiler) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for task := range p.tasks {
+		p.charge(task)
+	}
+	p.tasks = make(map[*interpreter.Task]*taskState)
+}
+
+// charge 把任务上一次事件到现在的时间记到它的当前行和调用栈上
+func (p *Profiler) charge(task *interpreter.Task) *taskState {
+	now := p.now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+	if now.After(p.last) {
+		p.last = now
+	}
+
+	t, ok := p.tasks[task]
+	if !ok {
+		t = &taskState{}
+		p.tasks[task] = t
+	}
+	if len(t.current) > 0 {
+		elapsed := now.Sub(t.last)
+		p.lineStat(t.current[0].line).Time += elapsed
+		p.sampleOf(t.current).nanos += int64(elapsed)
+	}
+	t.last = now
+	return t
+}
+
+func (t *taskState) active(name string) bool {
+	for _, call := range t.calls {
+		if call.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Profiler) lineStat(line int) *LineStat {
+	stat, ok := p.lines[line]
+	if !ok {
+		stat = &LineStat{Line: line}
+		p.lines[line] = stat
+	}
+	return stat
+}
+
+func (p *Profiler) sampleOf(stack []frame) *sample {
+	parts := make([]string, len(stack))
+	for i, f := range stack {
+		parts[i] = fmt.Sprintf("%s:%d", f.name, f.line)
+	}
+	key := strings.Join(parts, ";")
+
+	s, ok := p.samples[key]
+	if !ok {
+		s = &sample{stack: stack}
+		p.samples[key] = s
+	}
+	return s
+}
+
+func stackOf(frames []interpreter.Frame) []frame {
+	stack := make([]frame, 0, len(frames))
+	for i := len(frames) - 1; i >= 0; i-- {
+		stack = append(stack, frame{name: frames[i].Name, line: frames[i].Line})
+	}
+	return stack
+}
+
+// Functions 按累计时间降序排列的函数统计
+func (p *Profiler) Functions() []FuncStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stats []FuncStat
+	for _, s := range p.funcs {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Cum != stats[j].Cum {
+			return stats[i].Cum > stats[j].Cum
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// Lines 按行号排列的行统计
+func (p *Profiler) Lines() []LineStat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var stats []LineStat
+	for _, s := range p.lines {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Line < stats[j].Line })
+	return stats
+}
+
+// WriteReport 文本格式的报告
+func (p *Profiler) WriteReport(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%-20s %10s %12s %12s\n", "function", "calls", "cum(ms)", "self(ms)")
+	for _, s := range p.Functions() {
+		fmt.Fprintf(&b, "%-20s %10d %12.3f %12.3f\n", s.Name, s.Calls, ms(s.Cum), ms(s.Self))
+	}
+
+	fmt.Fprintf(&b, "\n%-20s %10s %12s\n", "line", "count", "time(ms)")
+	for _, s := range p.Lines() {
+		fmt.Fprintf(&b, "%-20d %10d %12.3f\n", s.Line, s.Count, ms(s.Time))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
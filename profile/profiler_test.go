@@ -0,0 +1,161 @@
+package profile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+func parse(t *testing.T, source string) []expr.Stmt {
+	t.Helper()
+
+	s := scanner.NewScanner(source)
+	s.SetErrFunc(func(line, column int, msg string) {
+		t.Fatalf("scan error on line %d: %s", line, msg)
+	})
+	p := parser.NewParser(s.ScanTokens(), func(tk *token.Token, msg string) {
+		t.Fatalf("parse error at '%s': %s", tk.Lexeme, msg)
+	})
+	return p.Parse()
+}
+
+func profileRun(t *testing.T, source string) *Profiler {
+	t.Helper()
+
+	profiler := NewProfiler()
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(ioutil.Discard)
+	itp.SetHook(profiler)
+	if err := itp.Run(parse(t, source)); err != nil {
+		t.Fatal(err)
+	}
+	profiler.Stop()
+	return profiler
+}
+
+func calls(p *Profiler) map[string]int {
+	m := make(map[string]int)
+	for _, s := range p.Functions() {
+		m[s.Name] = s.Calls
+	}
+	return m
+}
+
+// TestSpawnedTasks 在 go test -race 下运行; 每个任务有自己的调用栈, 不会弹出其他任务的调用
+func TestSpawnedTasks(t *testing.T) {
+	p := profileRun(t, `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+fun worker(n, done) {
+  done.send(fib(n));
+}
+var done = channel(8);
+for (var i in range(8)) spawn worker(10, done);
+var sum = 0;
+for (var i in range(8)) sum += done.recv();
+print sum;
+`)
+
+	got := calls(p)
+	if got["worker"] != 8 || got["fib"] != 8*177 {
+		t.Errorf("got calls %v, want 8 worker and %d fib", got, 8*177)
+	}
+	if len(p.tasks) != 0 {
+		t.Errorf("%d tasks left after Stop", len(p.tasks))
+	}
+	for _, s := range p.Functions() {
+		if s.Self < 0 || s.Self > s.Cum {
+			t.Errorf("%s: self %v, cum %v", s.Name, s.Self, s.Cum)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	p := profileRun(t, `
+fun square(x) { return x * x; }
+fun squares(n) {
+  for (var i in range(n)) yield square(i);
+}
+var total = 0;
+for (var x in squares(10)) total += x;
+print total;
+`)
+
+	got := calls(p)
+	if got["square"] != 10 {
+		t.Errorf("got calls %v, want 10 square", got)
+	}
+}
+
+// TestGeneratorTime 生成器函数体的时间记在生成器函数上, 每次恢复执行算一次调用
+func TestGeneratorTime(t *testing.T) {
+	profiler := NewProfiler()
+	clock := time.Unix(0, 0)
+	profiler.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(ioutil.Discard)
+	itp.SetHook(profiler)
+	if err := itp.Run(parse(t, `
+fun count(n) {
+  for (var i in range(n)) {
+    var x = i;
+    yield x;
+  }
+}
+fun consume(g) {
+  for (var x in g) print x;
+}
+consume(count(3));
+`)); err != nil {
+		t.Fatal(err)
+	}
+	profiler.Stop()
+
+	stats := make(map[string]FuncStat)
+	for _, s := range profiler.Functions() {
+		stats[s.Name] = s
+	}
+	count, consume := stats["count"], stats["consume"]
+	if count.Calls != 4 {
+		t.Fatalf("got %+v, want 4 calls to count", count)
+	}
+	if count.Self <= 0 || count.Cum != count.Self {
+		t.Errorf("count: self %v, cum %v", count.Self, count.Cum)
+	}
+	if consume.Cum != consume.Self+count.Cum {
+		t.Errorf("consume: self %v, cum %v, count cum %v", consume.Self, consume.Cum, count.Cum)
+	}
+}
+
+func TestReport(t *testing.T) {
+	p := profileRun(t, "fun f() { return 1; }\nprint f();\nprint f();\n")
+
+	var report bytes.Buffer
+	if err := p.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "f                             2") {
+		t.Errorf("report does not count two calls to f:\n%s", report.String())
+	}
+
+	var pprof bytes.Buffer
+	if err := p.WritePprof(&pprof, "test.lox"); err != nil {
+		t.Fatal(err)
+	}
+	if pprof.Len() == 0 {
+		t.Error("empty pprof output")
+	}
+}
@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+// Tracer 把每条执行的语句写到 w, 包含行号和环境深度;
+// spawn 出的任务在不同的 goroutine 中调用钩子, 所以每一行在 mu 保护下整行写出
+type Tracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+// BeforeExecute 实现 interpreter.Hook
+func (t *Tracer) BeforeExecute(itp *interpreter.Interpreter, stmt expr.Stmt) {
+	frames := itp.Frames()
+	line := fmt.Sprintf("[trace] line %d depth %d %s: %s\n",
+		stmt.Line(), itp.Env().Depth(), frames[len(frames)-1].Name, Describe(stmt))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, line)
+}
+
+// Describe 语句的简短描述
+func Describe(stmt expr.Stmt) string {
+	switch s := stmt.(type) {
+	case *expr.Expression:
+		return "expression"
+	case *expr.Function:
+		return "fun " + s.Name.Lexeme
+	case *expr.IF:
+		return "if"
+	case *expr.Print:
+		return "print"
+	case *expr.Return:
+		return "return"
+	case *expr.Yield:
+		return "yield"
+	case *expr.Var:
+		return "var " + s.Name.Lexeme
+	case *expr.While:
+		return "while"
+	case *expr.ForIn:
+		return "for " + s.Name.Lexeme + " in"
+	case *expr.Block:
+		return "block"
+	case *expr.Assert:
+		return "assert"
+	case *expr.Test:
+		return "test " + s.Name.Lexeme
+	case *expr.Match:
+		return "match"
+	case *expr.Spawn:
+		return "spawn"
+	case *expr.Select:
+		return "select"
+	default:
+		return fmt.Sprintf("%T", stmt)
+	}
+}
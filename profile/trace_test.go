@@ -0,0 +1,68 @@
+package profile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhiruchen/lox-go/interpreter"
+)
+
+// exclusiveWriter 记录是否有两个 Write 同时进行; 解释器自身的锁会让 -race 看不到 writer 上的竞争
+type exclusiveWriter struct {
+	busy    int32
+	overlap int32
+	buf     bytes.Buffer
+}
+
+func (w *exclusiveWriter) Write(p []byte) (int, error) {
+	if !atomic.CompareAndSwapInt32(&w.busy, 0, 1) {
+		atomic.StoreInt32(&w.overlap, 1)
+		return len(p), nil
+	}
+	defer atomic.StoreInt32(&w.busy, 0)
+	time.Sleep(10 * time.Microsecond)
+	return w.buf.Write(p)
+}
+
+// TestTraceSpawnedTasks 在 go test -race 下运行; 多个任务同时写同一个 writer, 每一行保持完整
+func TestTraceSpawnedTasks(t *testing.T) {
+	var w exclusiveWriter
+	itp := interpreter.NewInterpreter()
+	itp.SetOutput(ioutil.Discard)
+	itp.SetHook(NewTracer(&w))
+	err := itp.Run(parse(t, `
+fun worker(n, done) {
+  var sum = 0;
+  for (var i in range(n)) sum = sum + i;
+  done.send(sum);
+}
+var done = channel();
+for (var i in range(4)) spawn worker(50, done);
+for (var i in range(4)) print done.recv();
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if atomic.LoadInt32(&w.overlap) != 0 {
+		t.Fatal("trace lines written concurrently")
+	}
+	out := &w.buf
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	workers := 0
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "[trace] line ") {
+			t.Fatalf("garbled trace line %q", line)
+		}
+		if strings.Contains(line, " worker: ") {
+			workers++
+		}
+	}
+	if workers == 0 {
+		t.Fatalf("no trace lines from spawned tasks:\n%s", out.String())
+	}
+}
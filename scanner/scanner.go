@@ -1,56 +1,97 @@
 package scanner
 
 import (
+	"math"
+	"math/big"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode"
 
 	"github.com/zhiruchen/lox-go/common"
 	"github.com/zhiruchen/lox-go/lox"
 	"github.com/zhiruchen/lox-go/token"
 )
 
+// ErrFunc 扫描错误的回调, column 从 1 开始, 按字符计算
+type ErrFunc func(line, column int, msg string)
+
 // Scanner lox scanner
 type Scanner struct {
-	source   string
-	runes    []rune
-	tokens   []*token.Token
-	start    int
-	current  int
-	line     int
-	keywords map[string]token.Type
+	source    string
+	runes     []rune
+	tokens    []*token.Token
+	start     int
+	current   int
+	line      int
+	lineStart int
+	startLine int
+	startCol  int
+	errFunc   ErrFunc
+
+	// interpolations 每层未结束的 ${ 中还没有闭合的 { 的个数
+	interpolations []int
+}
+
+// keywords 保留字, 扫描成对应的 token 而不是 Identifier
+var keywords = map[string]token.Type{
+	"and":    token.And,
+	"assert": token.Assert,
+	"case":   token.Case,
+	"class":  token.Class,
+	"else":   token.Else,
+	"false":  token.False,
+	"for":    token.For,
+	"fun":    token.Fun,
+	"if":     token.If,
+	"match":  token.Match,
+	"nil":    token.Nil,
+	"or":     token.OR,
+	"print":  token.Print,
+	"super":  token.Super,
+	"this":   token.This,
+	"return": token.Return,
+	"select": token.Select,
+	"spawn":  token.Spawn,
+	"true":   token.True,
+	"var":    token.Var,
+	"while":  token.While,
+	"yield":  token.Yield,
+}
+
+// Keywords 所有保留字, 按字母排序
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 // NewScanner a new scanner
 func NewScanner(source string) *Scanner {
 	return &Scanner{
-		source: source,
-		runes:  []rune(source),
-		tokens: []*token.Token{},
-		line:   1,
-		keywords: map[string]token.Type{
-			"and":    token.And,
-			"class":  token.Class,
-			"else":   token.Else,
-			"false":  token.False,
-			"for":    token.For,
-			"fun":    token.Fun,
-			"if":     token.If,
-			"nil":    token.Nil,
-			"or":     token.OR,
-			"print":  token.Print,
-			"super":  token.Super,
-			"this":   token.This,
-			"return": token.Return,
-			"true":   token.True,
-			"var":    token.Var,
-			"while":  token.While,
-		},
+		source:  source,
+		runes:   []rune(source),
+		tokens:  []*token.Token{},
+		line:    1,
+		errFunc: lox.NewReporter(os.Stderr).ScanError,
 	}
 }
 
+// SetErrFunc 替换默认的错误回调; 默认向 os.Stderr 输出, 每个 Scanner 使用自己的 Reporter
+func (scan *Scanner) SetErrFunc(errFunc ErrFunc) {
+	scan.errFunc = errFunc
+}
+
 // ScanTokens 返回扫描到的token列表
 func (scan *Scanner) ScanTokens() []*token.Token {
 	for !scan.isAtEnd() {
 		scan.start = scan.current
+		scan.startLine = scan.line
+		scan.startCol = scan.current - scan.lineStart + 1
 		scan.scanToken()
 	}
 	scan.tokens = append(
@@ -60,6 +101,7 @@ func (scan *Scanner) ScanTokens() []*token.Token {
 			Lexeme:    "",
 			Literal:   nil,
 			Line:      scan.line,
+			Column:    scan.current - scan.lineStart + 1,
 		},
 	)
 	return scan.tokens
@@ -77,49 +119,113 @@ func (scan *Scanner) scanToken() {
 	case ')':
 		scan.addToken(token.RightParen, nil)
 	case '{':
+		if n := len(scan.interpolations); n > 0 {
+			scan.interpolations[n-1]++
+		}
 		scan.addToken(token.LeftBrace, nil)
 	case '}':
+		if n := len(scan.interpolations); n > 0 {
+			scan.interpolations[n-1]--
+			if scan.interpolations[n-1] == 0 {
+				// 插值表达式结束, 继续扫描字符串剩下的部分
+				scan.interpolations = scan.interpolations[:n-1]
+				scan.getStr()
+				return
+			}
+		}
 		scan.addToken(token.RightBrace, nil)
+	case '[':
+		scan.addToken(token.LeftBracket, nil)
+	case ']':
+		scan.addToken(token.RightBracket, nil)
 	case ',':
 		scan.addToken(token.Comma, nil)
 	case '.':
 		scan.addToken(token.Dot, nil)
 	case '-':
-		scan.addToken(token.Minus, nil)
+		if scan.match('-') {
+			scan.addToken(token.MinusMinus, nil)
+		} else {
+			scan.addToken(common.ConditionalExp(scan.match('='), token.MinusEqual, token.Minus), nil)
+		}
 	case '+':
-		scan.addToken(token.Plus, nil)
+		if scan.match('+') {
+			scan.addToken(token.PlusPlus, nil)
+		} else {
+			scan.addToken(common.ConditionalExp(scan.match('='), token.PlusEqual, token.Plus), nil)
+		}
 	case ';':
 		scan.addToken(token.Semicolon, nil)
+	case '?':
+		scan.addToken(common.ConditionalExp(scan.match('?'), token.QuestionQuestion, token.Question), nil)
+	case ':':
+		scan.addToken(token.Colon, nil)
 	case '*':
-		scan.addToken(token.Star, nil)
+		if scan.match('*') {
+			scan.addToken(token.StarStar, nil)
+		} else {
+			scan.addToken(common.ConditionalExp(scan.match('='), token.StarEqual, token.Star), nil)
+		}
+	case '~':
+		scan.addToken(common.ConditionalExp(scan.match('/'), token.TildeSlash, token.Tilde), nil)
+	case '%':
+		scan.addToken(common.ConditionalExp(scan.match('='), token.PercentEqual, token.Percent), nil)
+	case '&':
+		scan.addToken(token.Ampersand, nil)
+	case '|':
+		scan.addToken(token.Pipe, nil)
+	case '^':
+		scan.addToken(token.Caret, nil)
 	case '!':
 		scan.addToken(common.ConditionalExp(scan.match('='), token.BangEqual, token.Bang), nil)
 	case '=':
-		scan.addToken(common.ConditionalExp(scan.match('='), token.EqualEqual, token.Equal), nil)
+		if scan.match('>') {
+			scan.addToken(token.Arrow, nil)
+		} else {
+			scan.addToken(common.ConditionalExp(scan.match('='), token.EqualEqual, token.Equal), nil)
+		}
 	case '<':
-		scan.addToken(common.ConditionalExp(scan.match('='), token.LessEqual, token.Less), nil)
+		if scan.match('<') {
+			scan.addToken(token.LessLess, nil)
+		} else {
+			scan.addToken(common.ConditionalExp(scan.match('='), token.LessEqual, token.Less), nil)
+		}
 	case '>':
-		scan.addToken(common.ConditionalExp(scan.match('='), token.GreaterEqual, token.Greater), nil)
+		if scan.match('>') {
+			scan.addToken(token.GreaterGreater, nil)
+		} else {
+			scan.addToken(common.ConditionalExp(scan.match('='), token.GreaterEqual, token.Greater), nil)
+		}
 	case '/':
 		if scan.match('/') {
 			scan.skipLineComment()
 		} else if scan.match('*') {
 			scan.skipBlockComment()
 		} else {
-			scan.addToken(token.Slash, nil)
+			scan.addToken(common.ConditionalExp(scan.match('='), token.SlashEqual, token.Slash), nil)
 		}
 	case ' ', '\r', '\t': // 自动 break
 	case '\n':
-		scan.line++
+		scan.newLine()
 	case '"':
-		scan.getStr()
+		if scan.match('"') {
+			if scan.match('"') {
+				scan.getTripleStr()
+			} else {
+				scan.addToken(token.String, "")
+			}
+		} else {
+			scan.getStr()
+		}
+	case '`':
+		scan.getRawStr()
 	default:
 		if isDigits(c) {
 			scan.getNumber()
 		} else if isAlpha(c) {
 			scan.getIdentifier()
 		} else {
-			lox.LineError(scan.line, "Unexpected token!")
+			scan.errFunc(scan.startLine, scan.startCol, "Unexpected character.")
 		}
 	}
 }
@@ -131,7 +237,24 @@ func (scan *Scanner) advance() rune {
 
 func (scan *Scanner) addToken(tokenType token.Type, literal interface{}) {
 	text := string(scan.runes[scan.start:scan.current])
-	scan.tokens = append(scan.tokens, &token.Token{TokenType: tokenType, Lexeme: text, Literal: literal, Line: scan.line})
+	scan.tokens = append(scan.tokens, &token.Token{
+		TokenType: tokenType,
+		Lexeme:    text,
+		Literal:   literal,
+		Line:      scan.startLine,
+		Column:    scan.startCol,
+	})
+}
+
+// column 下一个字符所在的列
+func (scan *Scanner) column() int {
+	return scan.current - scan.lineStart + 1
+}
+
+// newLine 在 current 已经越过 '\n' 之后调用
+func (scan *Scanner) newLine() {
+	scan.line++
+	scan.lineStart = scan.current
 }
 
 func (scan *Scanner) match(expected rune) bool {
@@ -173,14 +296,10 @@ func (scan *Scanner) skipBlockComment() {
 	var nesting = 1
 	for nesting > 0 {
 		if scan.isAtEnd() {
-			lox.LineError(scan.line, "Unterminated block comment!")
+			scan.errFunc(scan.line, scan.column(), "Unterminated block comment.")
 			return
 		}
 
-		if scan.peek() == '\n' {
-			scan.line++
-		}
-
 		if scan.peek() == '/' && scan.peekNext() == '*' {
 			scan.advance()
 			scan.advance()
@@ -195,53 +314,423 @@ func (scan *Scanner) skipBlockComment() {
 			continue
 		}
 
-		scan.advance()
+		if scan.advance() == '\n' {
+			scan.newLine()
+		}
 	}
 }
 
+// getStr 双引号字符串, 遇到 ${ 时产生 Interpolation token 并开始扫描其中的表达式
 func (scan *Scanner) getStr() {
-	for scan.peek() != '"' && !scan.isAtEnd() {
-		if scan.peek() == '\n' {
-			scan.line++
-		}
-		scan.advance()
+	lines, end := scan.stringLines([]rune(`"`), true, true)
+	switch end {
+	case strUnterminated:
+		scan.errFunc(scan.line, scan.column(), "Unterminated string.")
+	case strInterpolation:
+		scan.interpolations = append(scan.interpolations, 1)
+		scan.addToken(token.Interpolation, scan.joinLines(lines, true))
+	default:
+		scan.addToken(token.String, scan.joinLines(lines, true))
 	}
-	if scan.isAtEnd() {
-		lox.LineError(scan.line, "Unterminated string")
+}
+
+// getRawStr 反引号字符串, 不处理转义, 可以跨行
+func (scan *Scanner) getRawStr() {
+	lines, end := scan.stringLines([]rune("`"), false, false)
+	if end == strUnterminated {
+		scan.errFunc(scan.line, scan.column(), "Unterminated raw string.")
 		return
 	}
+	scan.addToken(token.String, scan.joinLines(lines, false))
+}
+
+// getTripleStr 三引号字符串, 去掉开头和结尾的空行以及所有行共同的缩进
+//
+// 结尾 """ 之前的缩进也参与计算, 所以可以用它控制保留多少缩进
+func (scan *Scanner) getTripleStr() {
+	lines, end := scan.stringLines([]rune(`"""`), true, false)
+	if end == strUnterminated {
+		scan.errFunc(scan.line, scan.column(), "Unterminated string.")
+		return
+	}
+
+	if len(lines) > 1 && isBlank(lines[0].text) {
+		lines = lines[1:]
+	}
+
+	indent := -1
+	for i, l := range lines {
+		last := i == len(lines)-1 && len(lines) > 1
+		if isBlank(l.text) && !last {
+			continue
+		}
+		n := 0
+		for n < len(l.text) && isSpace(l.text[n]) {
+			n++
+		}
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	if n := len(lines); n > 1 && isBlank(lines[n-1].text) {
+		lines = lines[:n-1]
+	}
+
+	for i := range lines {
+		l := &lines[i]
+		if isBlank(l.text) {
+			l.text = nil
+			continue
+		}
+		l.text = l.text[indent:]
+		l.column += indent
+	}
+
+	scan.addToken(token.String, scan.joinLines(lines, true))
+}
+
+// strLine 字符串字面量中的一行, column 为 text 第一个字符所在的列
+type strLine struct {
+	text   []rune
+	line   int
+	column int
+}
+
+// stringEnd 字符串字面量的一段是如何结束的
+type stringEnd int
+
+const (
+	strUnterminated stringEnd = iota
+	strClosed
+	strInterpolation
+)
+
+// stringLines 读取到 closing 为止的内容, 按行切分; escapes 为 true 时 \ 后面的字符不会结束字符串,
+// interpolate 为 true 时也会在 ${ 之后停止
+func (scan *Scanner) stringLines(closing []rune, escapes, interpolate bool) ([]strLine, stringEnd) {
+	var lines []strLine
+	cur := strLine{line: scan.line, column: scan.column()}
+
+	for {
+		if scan.isAtEnd() {
+			return append(lines, cur), strUnterminated
+		}
+		if scan.hasPrefix(closing) {
+			scan.current += len(closing)
+			return append(lines, cur), strClosed
+		}
+		if interpolate && scan.hasPrefix([]rune("${")) {
+			scan.current += 2
+			return append(lines, cur), strInterpolation
+		}
+
+		c := scan.advance()
+		if c == '\n' {
+			lines = append(lines, cur)
+			scan.newLine()
+			cur = strLine{line: scan.line, column: 1}
+			continue
+		}
+
+		cur.text = append(cur.text, c)
+		if escapes && c == '\\' && !scan.isAtEnd() && scan.peek() != '\n' {
+			cur.text = append(cur.text, scan.advance())
+		}
+	}
+}
+
+func (scan *Scanner) hasPrefix(prefix []rune) bool {
+	if scan.current+len(prefix) > len(scan.runes) {
+		return false
+	}
+	for i, r := range prefix {
+		if scan.runes[scan.current+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (scan *Scanner) joinLines(lines []strLine, escapes bool) string {
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if escapes {
+			scan.unescape(&b, l)
+		} else {
+			b.WriteString(string(l.text))
+		}
+	}
+	return b.String()
+}
+
+var escapeChars = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'$':  '$',
+	'\'': '\'',
+	'\\': '\\',
+}
+
+// unescape 处理一行中的转义序列, 出错时报告反斜杠所在的位置
+func (scan *Scanner) unescape(b *strings.Builder, l strLine) {
+	text := l.text
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			b.WriteRune(text[i])
+			continue
+		}
 
-	scan.advance()
+		column := l.column + i
+		if i+1 == len(text) {
+			scan.errFunc(l.line, column, "Invalid escape sequence at end of line.")
+			continue
+		}
 
-	value := string(scan.runes[scan.start+1 : scan.current-1])
-	scan.addToken(token.String, value)
+		i++
+		if r, ok := escapeChars[text[i]]; ok {
+			b.WriteRune(r)
+			continue
+		}
+		if text[i] != 'u' {
+			scan.errFunc(l.line, column, "Invalid escape sequence '\\"+string(text[i])+"'.")
+			continue
+		}
+
+		// \u{XXXXXX}
+		end := i + 1
+		for end < len(text) && text[end] != '}' && end-i <= 7 {
+			end++
+		}
+		if i+1 >= len(text) || text[i+1] != '{' || end >= len(text) || text[end] != '}' || end-i < 3 {
+			scan.errFunc(l.line, column, "Invalid unicode escape, expect '\\u{XXXX}'.")
+			continue
+		}
+		code, err := strconv.ParseUint(string(text[i+2:end]), 16, 32)
+		if err != nil {
+			scan.errFunc(l.line, column, "Invalid unicode escape, expect '\\u{XXXX}'.")
+		} else if code > unicode.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+			scan.errFunc(l.line, column, "Invalid unicode code point.")
+		} else {
+			b.WriteRune(rune(code))
+		}
+		i = end
+	}
+}
+
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\r'
 }
 
+func isBlank(text []rune) bool {
+	for _, c := range text {
+		if !isSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// getNumber 十进制数字可以有小数和指数部分, 0x 0b 0o 前缀表示十六, 二, 八进制整数;
+// 数字之间可以用 _ 分隔. 没有小数和指数部分的是 int64, 否则是 float64;
+// 后缀 n 表示大整数 *big.Int, 后缀 m 表示十进制小数 *big.Rat.
+// 格式错误时报告错误, 值为 0
 func (scan *Scanner) getNumber() {
-	for isDigits(scan.peek()) {
-		scan.advance()
+	if scan.runes[scan.start] == '0' {
+		if base, ok := basePrefixes[scan.peek()]; ok {
+			scan.advance()
+			scan.getBasedNumber(base)
+			return
+		}
 	}
 
+	scan.skipDigits()
 	if scan.peek() == '.' && isDigits(scan.peekNext()) {
 		scan.advance()
+		scan.skipDigits()
+	}
 
-		for isDigits(scan.peek()) {
+	if c := scan.peek(); c == 'e' || c == 'E' {
+		next := scan.peekNext()
+		if isDigits(next) || next == '+' || next == '-' {
 			scan.advance()
+			if next == '+' || next == '-' {
+				scan.advance()
+			}
+			scan.skipDigits()
 		}
 	}
 
-	text := string(scan.runes[scan.start:scan.current])
-	number, _ := strconv.ParseFloat(text, 64)
+	text := scan.runes[scan.start:scan.current]
+	suffix := scan.numberSuffix()
+	if !scan.checkDigits(text, 0, 10) {
+		scan.addToken(token.Number, int64(0))
+		return
+	}
+
+	digits := strings.Replace(string(text), "_", "", -1)
+	switch suffix {
+	case 'n':
+		if strings.ContainsAny(digits, ".eE") {
+			scan.errFunc(scan.startLine, scan.startCol, "Big integer literal cannot have a fraction or exponent.")
+			scan.addToken(token.Number, int64(0))
+			return
+		}
+		n, _ := new(big.Int).SetString(digits, 10)
+		scan.addToken(token.Number, n)
+		return
+	case 'm':
+		r, _ := new(big.Rat).SetString(digits)
+		scan.addToken(token.Number, r)
+		return
+	}
+
+	if !strings.ContainsAny(digits, ".eE") {
+		scan.addInteger(digits, 10)
+		return
+	}
+
+	number, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		scan.errFunc(scan.startLine, scan.startCol, "Number literal out of range.")
+		number = 0
+	}
 	scan.addToken(token.Number, number)
 }
 
+var basePrefixes = map[rune]int{
+	'x': 16, 'X': 16,
+	'o': 8, 'O': 8,
+	'b': 2, 'B': 2,
+}
+
+var baseNames = map[int]string{
+	16: "hexadecimal",
+	8:  "octal",
+	2:  "binary",
+	10: "decimal",
+}
+
+// getBasedNumber 前缀之后的所有字母和数字都属于这个字面量, 例如 0xFG 中 G 是无效的数字
+func (scan *Scanner) getBasedNumber(base int) {
+	for isAlphaNumberic(scan.peek()) {
+		scan.advance()
+	}
+
+	digits := scan.runes[scan.start+2 : scan.current]
+	isBig := len(digits) > 0 && digits[len(digits)-1] == 'n'
+	if isBig {
+		digits = digits[:len(digits)-1]
+	}
+	if len(digits) == 0 {
+		scan.errFunc(scan.startLine, scan.startCol, "Missing digits after '"+string(scan.runes[scan.start:scan.start+2])+"'.")
+		scan.addToken(token.Number, int64(0))
+		return
+	}
+	if !scan.checkDigits(digits, 2, base) {
+		scan.addToken(token.Number, int64(0))
+		return
+	}
+
+	if isBig {
+		n, _ := new(big.Int).SetString(strings.Replace(string(digits), "_", "", -1), base)
+		scan.addToken(token.Number, n)
+		return
+	}
+
+	scan.addInteger(strings.Replace(string(digits), "_", "", -1), base)
+}
+
+// MinInt64Magnitude 值为 9223372036854775808 的整数字面量的 Literal; 它超出了 int64 的范围,
+// 只有紧跟在一元负号后面时才有意义, 由解析器折叠成 math.MinInt64, 在其他位置报告超出范围
+type MinInt64Magnitude struct{}
+
+var minInt64Magnitude = new(big.Int).Neg(big.NewInt(math.MinInt64))
+
+// addInteger 没有后缀的整数字面量, 超出 int64 时报错, 只有 MinInt64Magnitude 留给解析器处理
+func (scan *Scanner) addInteger(digits string, base int) {
+	n, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		if v, _ := new(big.Int).SetString(digits, base); v != nil && v.Cmp(minInt64Magnitude) == 0 {
+			scan.addToken(token.Number, MinInt64Magnitude{})
+			return
+		}
+		scan.errFunc(scan.startLine, scan.startCol, "Integer literal out of range.")
+		n = 0
+	}
+	scan.addToken(token.Number, n)
+}
+
+// numberSuffix 读取数字后面的 n 或 m 后缀, 后缀后面不能紧跟字母或数字
+func (scan *Scanner) numberSuffix() rune {
+	if c := scan.peek(); (c == 'n' || c == 'm') && !isAlphaNumberic(scan.peekNext()) {
+		scan.advance()
+		return c
+	}
+	return 0
+}
+
+func (scan *Scanner) skipDigits() {
+	for isDigits(scan.peek()) || scan.peek() == '_' {
+		scan.advance()
+	}
+}
+
+// checkDigits 检查数字和分隔符, offset 为 text 在字面量中的位置, 用于计算出错的列
+//
+// 十进制时 text 是整个字面量, '.', 'e' 和符号也会出现, 它们两边同样不能是 _
+func (scan *Scanner) checkDigits(text []rune, offset, base int) bool {
+	isDigit := func(i int) bool {
+		return i >= 0 && i < len(text) && digitValue(text[i]) < base
+	}
+
+	for i, c := range text {
+		column := scan.startCol + offset + i
+		switch {
+		case c == '_':
+			if !isDigit(i-1) || !isDigit(i+1) {
+				scan.errFunc(scan.startLine, column, "Digit separator '_' must be between digits.")
+				return false
+			}
+		case base == 10 && (c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'):
+			if c != '.' && !isDigit(len(text)-1) {
+				scan.errFunc(scan.startLine, column, "Missing digits in exponent.")
+				return false
+			}
+		case digitValue(c) >= base:
+			scan.errFunc(scan.startLine, column, "Invalid digit '"+string(c)+"' in "+baseNames[base]+" literal.")
+			return false
+		}
+	}
+	return true
+}
+
+// digitValue 字符作为数字的值, 不是数字时返回 36
+func digitValue(c rune) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10
+	}
+	return 36
+}
+
 func (scan *Scanner) getIdentifier() {
 	for isAlphaNumberic(scan.peek()) {
 		scan.advance()
 	}
 
 	text := string(scan.runes[scan.start:scan.current])
-	tokenType, ok := scan.keywords[text]
+	tokenType, ok := keywords[text]
 	if !ok {
 		tokenType = token.Identifier
 	}
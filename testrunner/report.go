@@ -0,0 +1,146 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText 类似 go test -v 的文本报告
+func WriteText(w io.Writer, files []*FileResult) {
+	passed, failed := 0, 0
+
+	for _, fr := range files {
+		if fr.Err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL\t%s\n%s\n", fr.File, indent(fr.Err.Error()))
+			continue
+		}
+
+		for _, r := range fr.Results {
+			if r.Passed {
+				passed++
+				fmt.Fprintf(w, "--- PASS: %s (%s)\n", r.Name, seconds(r.Duration))
+				continue
+			}
+
+			failed++
+			fmt.Fprintf(w, "--- FAIL: %s (%s)\n", r.Name, seconds(r.Duration))
+			fmt.Fprintf(w, "    %s: %s\n", r.Location(), r.Message)
+			if r.Output != "" {
+				fmt.Fprint(w, indent(strings.TrimRight(r.Output, "\n")), "\n")
+			}
+		}
+
+		status := "ok"
+		if fr.Failed() > 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", status, fr.File, seconds(fr.Duration))
+	}
+
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", passed, failed)
+}
+
+func indent(s string) string {
+	return "    " + strings.Replace(s, "\n", "\n    ", -1)
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit JUnit XML 格式的报告, 每个文件一个 testsuite
+func WriteJUnit(w io.Writer, files []*FileResult) error {
+	var suites junitTestSuites
+
+	for _, fr := range files {
+		suite := junitTestSuite{
+			Name: fr.File,
+			Time: fmt.Sprintf("%.3f", fr.Duration.Seconds()),
+		}
+
+		if fr.Err != nil {
+			suite.Tests, suite.Errors = 1, 1
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "parse",
+				Classname: fr.File,
+				File:      fr.File,
+				Time:      suite.Time,
+				Error:     &junitMessage{Message: "syntax error", Type: "SyntaxError", Text: fr.Err.Error()},
+			})
+			suites.Suites = append(suites.Suites, suite)
+			continue
+		}
+
+		for _, r := range fr.Results {
+			tc := junitTestCase{
+				Name:      r.Name,
+				Classname: fr.File,
+				File:      r.File,
+				Line:      r.Line,
+				Time:      fmt.Sprintf("%.3f", r.Duration.Seconds()),
+				SystemOut: r.Output,
+			}
+			if !r.Passed {
+				suite.Failures++
+				typ := "RuntimeError"
+				if strings.HasPrefix(r.Message, "Assertion failed") {
+					typ = "AssertionError"
+				}
+				tc.Failure = &junitMessage{
+					Message: r.Message,
+					Type:    typ,
+					Text:    r.Location() + ": " + r.Message,
+				}
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
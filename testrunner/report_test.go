@@ -0,0 +1,48 @@
+package testrunner_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/testrunner"
+)
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	testrunner.WriteText(&buf, run(t))
+
+	want := `--- PASS: square (0.000s)
+--- FAIL: square of negative (0.000s)
+    math_test.lox:11: Assertion failed: square(-2) should be 4
+    checking
+FAIL	math_test.lox	0.000s
+--- FAIL: undefined (0.000s)
+    sub/errors_test.lox:2: Undefined variable 'missing'.
+--- FAIL: native error (0.000s)
+    sub/errors_test.lox:6: repeat() count must be a non-negative integer.
+FAIL	sub/errors_test.lox	0.000s
+FAIL	sub/syntax_test.lox
+    [line 2] Error at ';': Expect expression.
+
+1 passed, 4 failed
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testrunner.WriteJUnit(&buf, run(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := ioutil.ReadFile("testdata/report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(golden) {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), golden)
+	}
+}
@@ -0,0 +1,192 @@
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhiruchen/lox-go/expr"
+	"github.com/zhiruchen/lox-go/interpreter"
+	"github.com/zhiruchen/lox-go/lox"
+	"github.com/zhiruchen/lox-go/parser"
+	"github.com/zhiruchen/lox-go/scanner"
+	"github.com/zhiruchen/lox-go/token"
+)
+
+// Suffix 测试文件的后缀
+const Suffix = "_test.lox"
+
+// Result 一个测试块的结果
+type Result struct {
+	Name     string
+	File     string
+	Line     int // 测试声明所在行
+	Passed   bool
+	Message  string // 失败的原因
+	FailLine int    // 失败的位置
+	Output   string // print 的输出
+	Duration time.Duration
+}
+
+// Location file:line 形式的失败位置
+func (r *Result) Location() string {
+	return fmt.Sprintf("%s:%d", r.File, r.FailLine)
+}
+
+// FileResult 一个测试文件的结果, 语法错误时 Err 不为空
+type FileResult struct {
+	File     string
+	Err      error
+	Results  []*Result
+	Duration time.Duration
+}
+
+// Failed 失败的测试个数
+func (fr *FileResult) Failed() int {
+	n := 0
+	for _, r := range fr.Results {
+		if !r.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// Discover 递归查找 root 下的测试文件; root 也可以是单个文件
+func Discover(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, Suffix) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// RunFile 解析测试文件, 在新的解释器中分别运行每个测试块
+//
+// 每个测试块运行之前都会先执行文件中的顶层语句(函数和变量声明等)
+func RunFile(path string) *FileResult {
+	start := time.Now()
+	fr := &FileResult{File: path}
+	defer func() {
+		fr.Duration = time.Since(start)
+	}()
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		fr.Err = err
+		return fr
+	}
+
+	statements, err := parse(string(source))
+	if err != nil {
+		fr.Err = err
+		return fr
+	}
+
+	var setup []expr.Stmt
+	var tests []*expr.Test
+	for _, stmt := range statements {
+		if t, ok := stmt.(*expr.Test); ok {
+			tests = append(tests, t)
+		} else {
+			setup = append(setup, stmt)
+		}
+	}
+
+	for _, t := range tests {
+		fr.Results = append(fr.Results, runTest(path, setup, t))
+	}
+	return fr
+}
+
+func parse(source string) ([]expr.Stmt, error) {
+	var errs []string
+
+	s := scanner.NewScanner(source)
+	s.SetErrFunc(func(line, column int, msg string) {
+		errs = append(errs, fmt.Sprintf("[line %d:%d] Error: %s", line, column, msg))
+	})
+	p := parser.NewParser(s.ScanTokens(), func(tk *token.Token, msg string) {
+		errs = append(errs, fmt.Sprintf("[line %d] Error at '%s': %s", tk.Line, tk.Lexeme, msg))
+	})
+
+	statements := p.Parse()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return statements, nil
+}
+
+// lastLine 记录最后执行的语句所在行, 用于定位没有 token 的运行时错误;
+// spawn 出的任务会同时调用钩子
+type lastLine struct {
+	mu   sync.Mutex
+	line int
+}
+
+func (l *lastLine) BeforeExecute(itp *interpreter.Interpreter, stmt expr.Stmt) {
+	if _, ok := stmt.(*expr.Block); ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.line = stmt.Line()
+}
+
+func (l *lastLine) get() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.line
+}
+
+func runTest(path string, setup []expr.Stmt, t *expr.Test) *Result {
+	name, _ := t.Name.Literal.(string)
+	result := &Result{Name: name, File: path, Line: t.Line(), Passed: true}
+
+	var out bytes.Buffer
+	last := &lastLine{line: t.Line()}
+	itp := interpreter.NewInterpreterWithConfig(interpreter.FullAccess())
+	itp.SetOutput(&out)
+	itp.SetHook(last)
+
+	start := time.Now()
+	err := itp.Run(setup)
+	if err == nil {
+		// 测试体在全局环境下的新作用域中运行; Run 会等待其中 spawn 出的任务结束并返回它们的错误
+		err = itp.Run([]expr.Stmt{expr.NewBlockStmt(t.Body)})
+	}
+	result.Duration = time.Since(start)
+	result.Output = out.String()
+
+	if err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		result.FailLine = last.get()
+		if e, ok := err.(*lox.RuntimeError); ok && e.Tk != nil {
+			result.FailLine = e.Tk.Line
+		}
+	}
+	return result
+}
@@ -0,0 +1,194 @@
+package testrunner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhiruchen/lox-go/testrunner"
+)
+
+// files 测试用的目录内容; helper.lox 不是测试文件
+var files = map[string]string{
+	"math_test.lox": `fun square(x) {
+  return x * x;
+}
+
+test "square" {
+  assert square(3) == 9;
+}
+
+test "square of negative" {
+  print "checking";
+  assert square(-2) == 5, "square(-2) should be 4";
+}
+`,
+	"sub/errors_test.lox": `test "undefined" {
+  print missing;
+}
+
+test "native error" {
+  "abc".repeat(-1);
+}
+`,
+	"sub/syntax_test.lox": `test "broken" {
+  print ;
+}
+`,
+	"helper.lox": `print "not a test";
+`,
+}
+
+// run 在临时目录中运行所有测试文件, 结果中的路径相对于该目录, 耗时清零以便比较
+func run(t *testing.T) []*testrunner.FileResult {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := testrunner.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*testrunner.FileResult
+	for _, path := range paths {
+		fr := testrunner.RunFile(path)
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fr.File, fr.Duration = filepath.ToSlash(rel), 0
+		for _, r := range fr.Results {
+			r.File, r.Duration = fr.File, 0
+		}
+		results = append(results, fr)
+	}
+	return results
+}
+
+func TestDiscover(t *testing.T) {
+	results := run(t)
+
+	var names []string
+	for _, fr := range results {
+		names = append(names, fr.File)
+	}
+	want := []string{"math_test.lox", "sub/errors_test.lox", "sub/syntax_test.lox"}
+	if len(names) != len(want) {
+		t.Fatalf("discovered %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("discovered %v, want %v", names, want)
+		}
+	}
+
+	// 单个文件直接返回, 不检查后缀
+	single, err := testrunner.Discover("runner_test.go")
+	if err != nil || len(single) != 1 {
+		t.Errorf("Discover(file) = %v, %v", single, err)
+	}
+	if _, err := testrunner.Discover("missing"); err == nil {
+		t.Error("Discover of a missing path succeeded")
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	results := run(t)
+
+	tests := []struct {
+		file     int
+		index    int
+		name     string
+		passed   bool
+		message  string
+		failLine int
+		output   string
+	}{
+		{0, 0, "square", true, "", 0, ""},
+		{0, 1, "square of negative", false, "Assertion failed: square(-2) should be 4", 11, "checking\n"},
+		{1, 0, "undefined", false, "Undefined variable 'missing'.", 2, ""},
+		{1, 1, "native error", false, "repeat() count must be a non-negative integer.", 6, ""},
+	}
+	for _, tt := range tests {
+		r := results[tt.file].Results[tt.index]
+		if r.Name != tt.name || r.Passed != tt.passed || r.Message != tt.message || r.FailLine != tt.failLine || r.Output != tt.output {
+			t.Errorf("%s: got %+v", tt.name, r)
+		}
+	}
+
+	if fr := results[2]; fr.Err == nil || fr.Err.Error() != "[line 2] Error at ';': Expect expression." || len(fr.Results) != 0 {
+		t.Errorf("syntax error file: got error %v and %d results", fr.Err, len(fr.Results))
+	}
+	if results[0].Failed() != 1 || results[1].Failed() != 2 {
+		t.Errorf("got %d and %d failures, want 1 and 2", results[0].Failed(), results[1].Failed())
+	}
+}
+
+// TestSetupPerTest 每个测试块都在新的解释器中运行, 顶层语句在每个测试之前重新执行
+func TestSetupPerTest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state_test.lox")
+	source := `var count = 0;
+test "first" {
+  count = count + 1;
+  assert count == 1;
+}
+test "second" {
+  count = count + 1;
+  assert count == 1;
+}
+`
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fr := testrunner.RunFile(path)
+	if fr.Err != nil || len(fr.Results) != 2 || fr.Failed() != 0 {
+		t.Errorf("got error %v, %d results and %d failures", fr.Err, len(fr.Results), fr.Failed())
+	}
+}
+
+// TestSpawnedTasks 在 go test -race 下运行: 测试体结束后等待 spawn 出的任务, 它们的失败也是测试的失败
+func TestSpawnedTasks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spawn_test.lox")
+	source := `fun check(n) {
+  for (var i in range(100)) {}
+  assert n == 2, "task saw ${n}";
+}
+test "spawned failure" {
+  spawn check(2);
+  spawn check(1);
+}
+test "spawned success" {
+  spawn check(2);
+  spawn check(2);
+  print "done";
+}
+`
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fr := testrunner.RunFile(path)
+	if fr.Err != nil || len(fr.Results) != 2 {
+		t.Fatalf("got error %v and %d results", fr.Err, len(fr.Results))
+	}
+	if r := fr.Results[0]; r.Passed || r.Message != "Assertion failed: task saw 1" || r.FailLine != 3 {
+		t.Errorf("spawned failure: got %+v", r)
+	}
+	if r := fr.Results[1]; !r.Passed || r.Output != "done\n" {
+		t.Errorf("spawned success: got %+v", r)
+	}
+}
@@ -8,6 +8,8 @@ type Token struct {
 	Lexeme    string
 	Literal   interface{}
 	Line      int
+	// Column 从 1 开始, 以 rune 计
+	Column int
 }
 
 // ToString token的字符串表示
@@ -11,6 +11,8 @@ const (
 
 	LeftBrace
 	RightBrace
+	LeftBracket
+	RightBracket
 
 	Comma
 	Dot
@@ -19,6 +21,32 @@ const (
 	Semicolon
 	Slash
 	Star
+	// TildeSlash ~/ 整数除法
+	TildeSlash
+	Percent
+	StarStar
+	Ampersand
+	Pipe
+	Caret
+	Tilde
+	LessLess
+	GreaterGreater
+
+	// 复合赋值和自增自减
+	PlusEqual
+	MinusEqual
+	StarEqual
+	SlashEqual
+	PercentEqual
+	PlusPlus
+	MinusMinus
+
+	// 条件表达式 a ? b : c 和 a ?? b
+	Question
+	QuestionQuestion
+	Colon
+	// Arrow => match 语句中分隔模式和语句
+	Arrow
 
 	Bang
 	BangEqual
@@ -31,6 +59,8 @@ const (
 
 	Identifier
 	String
+	// Interpolation 插值字符串中 ${ 之前的部分, 后面是表达式的 token
+	Interpolation
 	Number
 
 	// KeyWords
@@ -50,6 +80,12 @@ const (
 	True
 	Var
 	While
+	Assert
+	Match
+	Case
+	Yield
+	Spawn
+	Select
 
 	Eof
 )